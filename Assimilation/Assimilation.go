@@ -0,0 +1,299 @@
+package Assimilation
+
+import (
+	"demo2/Model"
+	"demo2/Watershed"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// 同化方法
+const (
+	EnKF           = "enkf" // 集合卡尔曼滤波
+	ParticleFilter = "pf"   // 粒子滤波，粒子权重逐时段乘以似然，有效粒子数不足时重采样
+)
+
+// Options 数据同化设置
+type Options struct {
+	Method   string  // 同化方法：enkf或pf
+	Members  int     // 集合成员（粒子）个数
+	PSigma   float64 // 降雨乘性扰动的对数标准差，扰动后降雨均值不变
+	EMSigma  float64 // 蒸发乘性扰动的相对标准差
+	ObsError float64 // 实测流量的相对误差标准差
+	MinError float64 // 实测流量误差标准差的下限，m3/s
+	Seed     int64   // 随机数种子
+}
+
+// Check 检查同化设置
+func (o *Options) Check() error {
+	if o.Method != EnKF && o.Method != ParticleFilter {
+		return fmt.Errorf("未知的同化方法%s，可选enkf或pf", o.Method)
+	}
+	if o.Members < 2 {
+		return fmt.Errorf("集合成员个数%d应不小于2", o.Members)
+	}
+	if o.PSigma < 0 || o.EMSigma < 0 || o.ObsError < 0 || o.MinError < 0 {
+		return fmt.Errorf("扰动及误差标准差不能为负")
+	}
+	if o.ObsError == 0 && o.MinError == 0 {
+		return fmt.Errorf("实测流量误差标准差不能为0")
+	}
+	return nil
+}
+
+// Result 同化结果，均为流域出口断面流量，m3/s
+type Result struct {
+	Prior       []float64 // 同化前的集合均值，粒子滤波为加权均值
+	PriorSpread []float64 // 同化前的集合标准差，粒子滤波为加权标准差
+	Mean        []float64 // 同化后的集合均值，粒子滤波为加权均值
+	Spread      []float64 // 同化后的集合标准差，粒子滤波为加权标准差
+	Updated     int       // 进行了同化的时段数
+	Resampled   int       // 粒子滤波重采样次数
+}
+
+// Filter 集合数据同化：各成员以扰动后的降雨、蒸发逐时段计算，有实测流量的时段
+// 以EnKF或粒子滤波更新各成员的张力水蓄量WU、WL、WD，自由水蓄量S0及汇流、河道状态
+type Filter struct {
+	options Options
+	members []*Model.Model
+	weights []float64 // 各成员的权重，和为1；EnKF始终为等权重，粒子滤波跨时段保留
+	rng     *rand.Rand
+}
+
+// NewFilter 创建集合，build按给定的流域信息（降雨、蒸发已扰动）创建一个成员的模型，
+// 各成员的参数、初始状态、河网等设置由build完成
+func NewFilter(watershed *Watershed.Watershed, options Options, build func(*Watershed.Watershed) (*Model.Model, error)) (*Filter, error) {
+	if err := options.Check(); err != nil {
+		return nil, err
+	}
+
+	f := &Filter{
+		options: options,
+		rng:     rand.New(rand.NewSource(options.Seed)),
+	}
+	for i := 0; i < options.Members; i++ {
+		member, err := build(f.perturb(watershed))
+		if err != nil {
+			return nil, err
+		}
+		f.members = append(f.members, member)
+	}
+	f.weights = uniform(options.Members)
+	return f, nil
+}
+
+// perturb 返回降雨、蒸发经扰动的流域信息，同一时段各单元流域取相同的扰动
+func (f *Filter) perturb(watershed *Watershed.Watershed) *Watershed.Watershed {
+	ws := *watershed
+	ws.P = make([][]float64, len(watershed.P))
+	ws.EM = make([][]float64, len(watershed.EM))
+	sigma := f.options.PSigma
+	for t := range watershed.P {
+		pf := math.Exp(sigma*f.rng.NormFloat64() - sigma*sigma/2)
+		ef := math.Max(1+f.options.EMSigma*f.rng.NormFloat64(), 0)
+		ws.P[t] = make([]float64, len(watershed.P[t]))
+		ws.EM[t] = make([]float64, len(watershed.EM[t]))
+		for w := range watershed.P[t] {
+			ws.P[t][w] = watershed.P[t][w] * pf
+			ws.EM[t][w] = watershed.EM[t][w] * ef
+		}
+	}
+	return &ws
+}
+
+// Run 逐时段计算nT个时段并同化实测流量observed，实测缺测（NaN）的时段不同化
+func (f *Filter) Run(observed []float64, nT int) (*Result, error) {
+	result := &Result{
+		Prior:       make([]float64, nT),
+		PriorSpread: make([]float64, nT),
+		Mean:        make([]float64, nT),
+		Spread:      make([]float64, nT),
+	}
+
+	q := make([]float64, len(f.members))
+	for t := 0; t < nT; t++ {
+		for i, member := range f.members {
+			q[i] = member.Step(t)
+		}
+		result.Prior[t], result.PriorSpread[t] = meanStd(q, f.weights)
+
+		if t < len(observed) && !math.IsNaN(observed[t]) {
+			variance := math.Pow(math.Max(f.options.ObsError*observed[t], f.options.MinError), 2)
+			switch f.options.Method {
+			case EnKF:
+				f.enkf(q, observed[t], variance)
+			case ParticleFilter:
+				resampled, err := f.reweight(q, observed[t], variance)
+				if err != nil {
+					return nil, fmt.Errorf("第%d时段重采样失败: %w", t+1, err)
+				}
+				if resampled {
+					result.Resampled++
+				}
+			}
+			result.Updated++
+		}
+		result.Mean[t], result.Spread[t] = meanStd(q, f.weights)
+	}
+	return result, nil
+}
+
+// enkf 以扰动实测值的EnKF更新各成员状态，q为各成员模拟流量，更新后为分析值
+func (f *Filter) enkf(q []float64, observed, variance float64) {
+	n := len(f.members)
+	x := make([][]float64, n)
+	for i, member := range f.members {
+		x[i] = stateVector(member)
+	}
+	qMean, qStd := meanStd(q, f.weights)
+	if qStd == 0 {
+		return
+	}
+
+	// 增广状态与模拟流量的协方差，卡尔曼增益 K = Cov(x, q) / (Var(q) + R)
+	gain := make([]float64, len(x[0]))
+	for j := range gain {
+		mean := 0.0
+		for i := range x {
+			mean += x[i][j]
+		}
+		mean /= float64(n)
+		cov := 0.0
+		for i := range x {
+			cov += (x[i][j] - mean) * (q[i] - qMean)
+		}
+		gain[j] = cov / float64(n-1) / (qStd*qStd + variance)
+	}
+	qGain := qStd * qStd / (qStd*qStd + variance)
+
+	for i, member := range f.members {
+		innovation := observed + math.Sqrt(variance)*f.rng.NormFloat64() - q[i]
+		for j := range x[i] {
+			x[i][j] += gain[j] * innovation
+		}
+		setStateVector(member, x[i])
+		q[i] = math.Max(q[i]+qGain*innovation, 0)
+	}
+}
+
+// reweight 将各粒子权重乘以高斯似然后归一化，有效粒子数小于一半时系统重采样并将权重重置为等权重，返回是否重采样
+// 不重采样时q不变，重采样后q为各粒子的模拟流量
+func (f *Filter) reweight(q []float64, observed, variance float64) (bool, error) {
+	n := len(f.members)
+	logWeights := make([]float64, n)
+	maxLog := math.Inf(-1)
+	for i := range q {
+		logWeights[i] = math.Log(f.weights[i]) - (observed-q[i])*(observed-q[i])/(2*variance)
+		maxLog = math.Max(maxLog, logWeights[i])
+	}
+	sum := 0.0
+	for i := range logWeights {
+		f.weights[i] = math.Exp(logWeights[i] - maxLog)
+		sum += f.weights[i]
+	}
+	sumSquares := 0.0
+	for i := range f.weights {
+		f.weights[i] /= sum
+		sumSquares += f.weights[i] * f.weights[i]
+	}
+	if 1/sumSquares >= float64(n)/2 {
+		return false, nil
+	}
+
+	// 系统重采样，被选中粒子的状态复制给其他粒子
+	snapshots := make([]*Model.Snapshot, n)
+	for i, member := range f.members {
+		snapshots[i] = member.Snapshot(0)
+	}
+	selected := make([]float64, n)
+	u := f.rng.Float64() / float64(n)
+	cumulative, k := f.weights[0], 0
+	for i, member := range f.members {
+		for u > cumulative && k < n-1 {
+			k++
+			cumulative += f.weights[k]
+		}
+		if err := member.Restore(snapshots[k]); err != nil {
+			return false, err
+		}
+		selected[i] = q[k]
+		u += 1 / float64(n)
+	}
+	copy(q, selected)
+	f.weights = uniform(n)
+	return true, nil
+}
+
+// stateVector 返回成员的状态向量：各单元流域WU、WL、WD、S0、QS、QI、QG、QU、O，各河段QU、O
+func stateVector(m *Model.Model) []float64 {
+	var x []float64
+	for _, s := range m.States() {
+		x = append(x, s.WU, s.WL, s.WD, s.S0, s.QS, s.QI, s.QG, s.QU)
+		x = append(x, s.O...)
+	}
+	for _, r := range m.Reaches() {
+		x = append(x, r.QU)
+		x = append(x, r.O...)
+	}
+	return x
+}
+
+// setStateVector 将状态向量写回成员，并将各状态限制在参数给定的范围内
+func setStateVector(m *Model.Model, x []float64) {
+	k := 0
+	next := func(lower, upper float64) float64 {
+		v := math.Min(math.Max(x[k], lower), upper)
+		k++
+		return v
+	}
+	inf := math.Inf(1)
+	for w, s := range m.States() {
+		p := m.UnitParameter(w)
+		s.WU = next(0, p.UM)
+		s.WL = next(0, p.LM)
+		s.WD = next(0, p.WM-p.UM-p.LM)
+		s.W = s.WU + s.WL + s.WD
+		s.S0 = next(0, p.SM)
+		s.QS = next(0, inf)
+		s.QI = next(0, inf)
+		s.QG = next(0, inf)
+		s.QU = next(0, inf)
+		for i := range s.O {
+			s.O[i] = next(0, inf)
+		}
+	}
+	for _, r := range m.Reaches() {
+		r.QU = next(0, inf)
+		for i := range r.O {
+			r.O[i] = next(0, inf)
+		}
+	}
+}
+
+// meanStd 返回加权均值及加权样本标准差，等权重时即为均值及样本标准差
+func meanStd(values, weights []float64) (float64, float64) {
+	mean, sumSquares := 0.0, 0.0
+	for i, v := range values {
+		mean += weights[i] * v
+		sumSquares += weights[i] * weights[i]
+	}
+	if sumSquares >= 1 {
+		// 权重集中于一个成员时离散程度为0
+		return mean, 0
+	}
+	variance := 0.0
+	for i, v := range values {
+		variance += weights[i] * (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(variance / (1 - sumSquares))
+}
+
+// uniform 返回n个成员的等权重
+func uniform(n int) []float64 {
+	weights := make([]float64, n)
+	for i := range weights {
+		weights[i] = 1 / float64(n)
+	}
+	return weights
+}
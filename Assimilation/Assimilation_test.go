@@ -0,0 +1,131 @@
+package Assimilation
+
+import (
+	"demo2/Data"
+	"demo2/Model"
+	"demo2/Watershed"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestMeanStd(t *testing.T) {
+	values := []float64{1, 2, 3, 4}
+	mean, std := meanStd(values, uniform(4))
+	if mean != 2.5 || math.Abs(std-math.Sqrt(5.0/3)) > 1e-12 {
+		t.Errorf("等权重时均值%g、标准差%g，应为2.5、%g", mean, std, math.Sqrt(5.0/3))
+	}
+	if mean, std := meanStd(values, []float64{0, 0, 1, 0}); mean != 3 || std != 0 {
+		t.Errorf("权重集中于一个成员时均值%g、标准差%g，应为3、0", mean, std)
+	}
+}
+
+// 未重采样时权重跨时段累积，两次更新等价于以两次似然之积更新
+func TestReweightKeepsWeights(t *testing.T) {
+	f := &Filter{members: make([]*Model.Model, 3), weights: uniform(3)}
+	q := []float64{9, 10, 11}
+	for step := 0; step < 2; step++ {
+		resampled, err := f.reweight(q, 10, 4)
+		if err != nil || resampled {
+			t.Fatalf("第%d次更新时重采样%v、错误%v，权重应保留", step+1, resampled, err)
+		}
+	}
+	// 每次似然之比为exp(-1/8)，两次为exp(-1/4)
+	want := 1 / (1 + 2*math.Exp(-0.25))
+	if math.Abs(f.weights[1]-want) > 1e-12 || math.Abs(f.weights[0]-f.weights[2]) > 1e-12 {
+		t.Errorf("权重为%v，中间成员应为%g", f.weights, want)
+	}
+	if sum := f.weights[0] + f.weights[1] + f.weights[2]; math.Abs(sum-1) > 1e-12 {
+		t.Errorf("权重之和为%g，应为1", sum)
+	}
+}
+
+// 各成员似然均下溢为0时在对数域归一化，权重不应为NaN
+func TestReweightUnderflow(t *testing.T) {
+	f := &Filter{members: make([]*Model.Model, 2), weights: uniform(2)}
+	if _, err := f.reweight([]float64{1e6 + 1, 1e6}, 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if f.weights[0] != 0 || f.weights[1] != 1 {
+		t.Errorf("权重为%v，应为[0 1]", f.weights)
+	}
+}
+
+// newExampleFilter 以IOexamples的流域及参数创建集合，各成员从默认初始状态开始
+func newExampleFilter(t *testing.T, options Options) *Filter {
+	t.Helper()
+	path := "../IOexamples/"
+	watershed, io, parameter := &Watershed.Watershed{}, &Watershed.IO{}, &Data.Parameter{}
+	if err := watershed.ReadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := io.ReadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := watershed.Calculate(io); err != nil {
+		t.Fatal(err)
+	}
+	if err := parameter.ReadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	f, err := NewFilter(watershed, options, func(ws *Watershed.Watershed) (*Model.Model, error) {
+		return Model.NewModel(ws, parameter)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// 相同的随机数种子下同化结果完全相同，EnKF更新后集合均值向实测值靠近、离散程度减小
+func TestEnKF(t *testing.T) {
+	const nT = 300
+	options := Options{Method: EnKF, Members: 30, PSigma: 0.3, EMSigma: 0.1, ObsError: 0.05, MinError: 0.1, Seed: 3}
+	observed := make([]float64, nT)
+	for i := range observed {
+		observed[i] = math.NaN()
+	}
+	free, err := newExampleFilter(t, options).Run(observed, nT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := newExampleFilter(t, options).Run(observed, nT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(free, again) {
+		t.Error("相同的随机数种子下两次同化结果不同")
+	}
+	if free.Updated != 0 || !reflect.DeepEqual(free.Prior, free.Mean) {
+		t.Error("没有实测值时不应更新集合")
+	}
+
+	// 在集合均值最大的时段给出高于均值3倍标准差的实测值
+	peak := 0
+	for i := range free.Prior {
+		if free.Prior[i] > free.Prior[peak] {
+			peak = i
+		}
+	}
+	if free.PriorSpread[peak] == 0 {
+		t.Fatalf("第%d时段集合离散程度为0", peak+1)
+	}
+	obs := free.Prior[peak] + 3*free.PriorSpread[peak]
+	observed[peak] = obs
+	result, err := newExampleFilter(t, options).Run(observed, nT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Updated != 1 {
+		t.Errorf("同化时段数为%d，应为1", result.Updated)
+	}
+	if !reflect.DeepEqual(result.Prior[:peak+1], free.Prior[:peak+1]) {
+		t.Error("同化时段及之前的先验集合均值应与不同化时相同")
+	}
+	if math.Abs(result.Mean[peak]-obs) >= math.Abs(result.Prior[peak]-obs) {
+		t.Errorf("同化后集合均值%g未向实测值%g靠近，同化前为%g", result.Mean[peak], obs, result.Prior[peak])
+	}
+	if result.Spread[peak] >= result.PriorSpread[peak] {
+		t.Errorf("同化后集合标准差%g未小于同化前的%g", result.Spread[peak], result.PriorSpread[peak])
+	}
+}
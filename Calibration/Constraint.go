@@ -0,0 +1,340 @@
+package Calibration
+
+import (
+	"demo2/Data"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"unicode"
+)
+
+// Constraint 参数间约束，在scein.txt中以“constraint 表达式 比较运算符 表达式”给出，如
+//
+//	constraint KI + KG <= 0.7
+//	constraint UM + LM <= WM - 10
+//
+// 表达式由参数名、数值、+ - * / ^、括号及函数sqrt、exp、log、abs、min、max、pow组成；
+// 参数名为待率定参数（可为“参数名@分组”）或关联参数时取候选点对应的值，否则取parameter.txt中的值
+// 比较运算符为<=、<、>=、>
+type Constraint struct {
+	Text     string       // 约束原文
+	lhs, rhs expression   // 比较运算符两侧的表达式
+	op       string       // 比较运算符
+	rejected atomic.Int64 // 因不满足该约束被舍弃的点数
+}
+
+// expression 以候选点参数值计算的表达式
+type expression func(x []float64) float64
+
+// Satisfied 返回候选点x是否满足约束，表达式无意义（NaN）时视为不满足
+func (c *Constraint) Satisfied(x []float64) bool {
+	l, r := c.lhs(x), c.rhs(x)
+	switch c.op {
+	case "<=":
+		return l <= r
+	case "<":
+		return l < r
+	case ">=":
+		return l >= r
+	default:
+		return l > r
+	}
+}
+
+// Rejected 返回因不满足该约束被舍弃的点数
+func (c *Constraint) Rejected() int64 {
+	return c.rejected.Load()
+}
+
+// ParseConstraint 解析约束，xname为取候选点值的参数名，其余参数名取parameter中的值
+func ParseConstraint(text string, xname []string, parameter *Data.Parameter) (*Constraint, error) {
+	tokens, err := tokenize(text)
+	if err != nil {
+		return nil, fmt.Errorf("约束“%s”: %w", text, err)
+	}
+	p := &parser{tokens: tokens, xname: xname, parameter: parameter}
+
+	c := &Constraint{Text: text}
+	if c.lhs, err = p.sum(); err == nil {
+		c.op = p.next()
+		switch c.op {
+		case "<=", "<", ">=", ">":
+			if c.rhs, err = p.sum(); err == nil && p.peek() != "" {
+				err = fmt.Errorf("多余的“%s”", p.peek())
+			}
+		default:
+			err = fmt.Errorf("缺少比较运算符<=、<、>=或>")
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("约束“%s”: %w", text, err)
+	}
+	return c, nil
+}
+
+// Tie 关联参数，在scein.txt中以“tie 参数名 = 表达式”给出，如“tie KG = 0.3 * KI”，
+// 其值由候选点的参数值按表达式计算，表达式的写法与约束相同，但不能引用其他关联参数
+type Tie struct {
+	Name  string     // 关联参数名，可为“参数名@分组”
+	Text  string     // 表达式原文
+	value expression // 由候选点计算关联参数值
+	refs  []string   // 表达式中取parameter.txt中的值的参数名
+}
+
+// Value 返回候选点x对应的关联参数值
+func (t *Tie) Value(x []float64) float64 {
+	return t.value(x)
+}
+
+// ParseTie 解析关联参数name = text，xname为待率定参数名，其余参数名取parameter中的值
+func ParseTie(name, text string, xname []string, parameter *Data.Parameter) (*Tie, error) {
+	t := &Tie{Name: name, Text: text}
+
+	tokens, err := tokenize(t.Text)
+	if err != nil {
+		return nil, fmt.Errorf("关联参数%s: %w", t.Name, err)
+	}
+	p := &parser{tokens: tokens, xname: xname, parameter: parameter}
+	if t.value, err = p.sum(); err == nil && p.peek() != "" {
+		err = fmt.Errorf("多余的“%s”", p.peek())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("关联参数%s: %w", t.Name, err)
+	}
+	t.refs = p.refs
+	return t, nil
+}
+
+// tokenize 将约束拆分为数值、名称、运算符及括号
+func tokenize(text string) ([]string, error) {
+	var tokens []string
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || r == '.':
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.' ||
+				(runes[j] == 'e' || runes[j] == 'E') ||
+				((runes[j] == '+' || runes[j] == '-') && (runes[j-1] == 'e' || runes[j-1] == 'E'))) {
+				j++
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i + 1
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_' || runes[j] == '@') {
+				j++
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+		case r == '<' || r == '>':
+			if i+1 < len(runes) && runes[i+1] == '=' {
+				tokens = append(tokens, string(runes[i:i+2]))
+				i += 2
+			} else {
+				tokens = append(tokens, string(r))
+				i++
+			}
+		case strings.ContainsRune("+-*/^(),", r):
+			tokens = append(tokens, string(r))
+			i++
+		default:
+			return nil, fmt.Errorf("无法识别的字符“%c”", r)
+		}
+	}
+	return tokens, nil
+}
+
+// parser 递归下降解析表达式
+// sum = term {("+"|"-") term}，term = unary {("*"|"/") unary}，unary = "-" unary | power，
+// power = primary ["^" unary]，primary = 数值 | 参数名 | 函数名 "(" sum {"," sum} ")" | "(" sum ")"
+type parser struct {
+	tokens    []string
+	pos       int
+	xname     []string
+	parameter *Data.Parameter
+	refs      []string // 取parameter中的值的参数名
+}
+
+// peek 返回下一个记号，没有时为空
+func (p *parser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+// next 返回并跳过下一个记号
+func (p *parser) next() string {
+	t := p.peek()
+	if t != "" {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) sum() (expression, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "+" || p.peek() == "-" {
+		op := p.next()
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		if op == "+" {
+			left = func(x []float64) float64 { return l(x) + right(x) }
+		} else {
+			left = func(x []float64) float64 { return l(x) - right(x) }
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) term() (expression, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "*" || p.peek() == "/" {
+		op := p.next()
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		if op == "*" {
+			left = func(x []float64) float64 { return l(x) * right(x) }
+		} else {
+			left = func(x []float64) float64 { return l(x) / right(x) }
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) unary() (expression, error) {
+	if p.peek() == "-" {
+		p.next()
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(x []float64) float64 { return -operand(x) }, nil
+	}
+	return p.power()
+}
+
+func (p *parser) power() (expression, error) {
+	base, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != "^" {
+		return base, nil
+	}
+	p.next()
+	exponent, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	return func(x []float64) float64 { return math.Pow(base(x), exponent(x)) }, nil
+}
+
+func (p *parser) primary() (expression, error) {
+	t := p.next()
+	switch {
+	case t == "":
+		return nil, fmt.Errorf("表达式不完整")
+	case t == "(":
+		e, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("缺少“)”")
+		}
+		return e, nil
+	case unicode.IsDigit(rune(t[0])) || t[0] == '.':
+		value, err := strconv.ParseFloat(t, 64)
+		if err != nil {
+			return nil, fmt.Errorf("无法解析数值“%s”", t)
+		}
+		return func([]float64) float64 { return value }, nil
+	case unicode.IsLetter([]rune(t)[0]) || t[0] == '_':
+		if p.peek() == "(" {
+			return p.call(t)
+		}
+		return p.variable(t)
+	}
+	return nil, fmt.Errorf("意外的“%s”", t)
+}
+
+// variable 返回参数的取值：待率定参数取候选点的值，其余取模型参数值
+func (p *parser) variable(name string) (expression, error) {
+	for i, xname := range p.xname {
+		if xname == name {
+			return func(x []float64) float64 { return x[i] }, nil
+		}
+	}
+	if value, ok := p.parameter.Get(name); ok {
+		p.refs = append(p.refs, name)
+		return func([]float64) float64 { return value }, nil
+	}
+	return nil, fmt.Errorf("未知的参数%s", name)
+}
+
+// 约束中可用的函数及其参数个数
+var functions = map[string]struct {
+	args int
+	fn   func(a []float64) float64
+}{
+	"sqrt": {1, func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"exp":  {1, func(a []float64) float64 { return math.Exp(a[0]) }},
+	"log":  {1, func(a []float64) float64 { return math.Log(a[0]) }},
+	"abs":  {1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"min":  {2, func(a []float64) float64 { return math.Min(a[0], a[1]) }},
+	"max":  {2, func(a []float64) float64 { return math.Max(a[0], a[1]) }},
+	"pow":  {2, func(a []float64) float64 { return math.Pow(a[0], a[1]) }},
+}
+
+// call 解析函数调用，当前记号为“(”
+func (p *parser) call(name string) (expression, error) {
+	f, ok := functions[name]
+	if !ok {
+		return nil, fmt.Errorf("未知的函数%s", name)
+	}
+	p.next()
+
+	var args []expression
+	for {
+		arg, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if t := p.next(); t == ")" {
+			break
+		} else if t != "," {
+			return nil, fmt.Errorf("函数%s缺少“)”", name)
+		}
+	}
+	if len(args) != f.args {
+		return nil, fmt.Errorf("函数%s应有%d个参数，实际%d个", name, f.args, len(args))
+	}
+
+	return func(x []float64) float64 {
+		values := make([]float64, len(args))
+		for i, arg := range args {
+			values[i] = arg(x)
+		}
+		return f.fn(values)
+	}, nil
+}
@@ -0,0 +1,114 @@
+package Calibration
+
+import (
+	"demo2/Data"
+	"math"
+	"strings"
+	"testing"
+)
+
+// testParameter 返回约束中未率定参数取值所用的模型参数
+func testParameter() *Data.Parameter {
+	return &Data.Parameter{KC: 0.9, UM: 20, LM: 70, C: 0.15, WM: 150, B: 0.3, IM: 0.01, SM: 30, EX: 1.2,
+		KG: 0.3, KI: 0.4, CS: 0.2, CI: 0.7, CG: 0.98, CR: 0.2, KE: 24, XE: 0.3}
+}
+
+func TestParseConstraint(t *testing.T) {
+	xname := []string{"KI", "KG", "UM@upper"}
+	tests := []struct {
+		text string
+		x    []float64
+		want bool
+	}{
+		{"KI + KG <= 0.7", []float64{0.4, 0.3, 20}, true},
+		{"KI + KG <= 0.7", []float64{0.5, 0.3, 20}, false},
+		{"KI + KG < 0.7", []float64{0.4, 0.3, 20}, false},
+		{"UM + LM <= WM - 10", []float64{0.4, 0.3, 20}, true},
+		{"UM@upper + LM <= WM - 10", []float64{0.4, 0.3, 80}, false},
+		{"-KI >= -0.5", []float64{0.4, 0.3, 20}, true},
+		{"2 * (KI - KG) ^ 2 > 0.01", []float64{0.4, 0.3, 20}, true},
+		{"max(KI, KG) <= sqrt(0.25)", []float64{0.4, 0.3, 20}, true},
+		{"pow(KI, 2) + abs(-KG) < exp(0) - log(1)", []float64{0.4, 0.3, 20}, true},
+		{"1e-1 * KE <= 2.5", []float64{0.4, 0.3, 20}, true},
+		{"sqrt(KI - 1) <= 1", []float64{0.4, 0.3, 20}, false}, // NaN视为不满足
+	}
+	for _, tt := range tests {
+		c, err := ParseConstraint(tt.text, xname, testParameter())
+		if err != nil {
+			t.Errorf("%s: %v", tt.text, err)
+			continue
+		}
+		if got := c.Satisfied(tt.x); got != tt.want {
+			t.Errorf("%s对%v的结果为%t，应为%t", tt.text, tt.x, got, tt.want)
+		}
+	}
+}
+
+func TestParseConstraintErrors(t *testing.T) {
+	tests := []struct {
+		text string
+		err  string
+	}{
+		{"KI + KG", "缺少比较运算符"},
+		{"KI + KG = 0.7", "无法识别的字符“=”"},
+		{"KI + <= 0.7", "意外的“<=”"},
+		{"(KI + KG <= 0.7", "缺少“)”"},
+		{"KI <= 0.7)", "多余的“)”"},
+		{"FOO <= 1", "未知的参数FOO"},
+		{"foo(KI) <= 1", "未知的函数foo"},
+		{"max(KI) <= 1", "函数max应有2个参数"},
+		{"max(KI, KG <= 1", "函数max缺少“)”"},
+		{"KI # KG <= 1", "无法识别的字符"},
+		{"1.2.3 <= KI", "无法解析数值"},
+		{"KI <=", "表达式不完整"},
+	}
+	for _, tt := range tests {
+		_, err := ParseConstraint(tt.text, []string{"KI", "KG"}, testParameter())
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: 错误为%v，应包含“%s”", tt.text, err, tt.err)
+		}
+	}
+}
+
+func TestRejected(t *testing.T) {
+	c, err := ParseConstraint("KI <= 0.5", []string{"KI"}, testParameter())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.rejected.Add(3)
+	if c.Rejected() != 3 {
+		t.Errorf("舍弃点数为%d，应为3", c.Rejected())
+	}
+}
+
+func TestParseTie(t *testing.T) {
+	tests := []struct {
+		name, text string
+		x          []float64
+		want       float64
+		refs       []string
+	}{
+		{"KG", "0.3 * KI", []float64{0.5}, 0.15, nil},
+		{"LM", "WM - UM - 60", []float64{0.5}, 70, []string{"WM", "UM"}},
+		{"CG@upper", "min(KI * 2, 0.99)", []float64{0.6}, 0.99, nil},
+	}
+	for _, tt := range tests {
+		tie, err := ParseTie(tt.name, tt.text, []string{"KI"}, testParameter())
+		if err != nil {
+			t.Errorf("%s = %s: %v", tt.name, tt.text, err)
+			continue
+		}
+		if got := tie.Value(tt.x); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("%s = %s的值为%g，应为%g", tt.name, tt.text, got, tt.want)
+		}
+		if strings.Join(tie.refs, ",") != strings.Join(tt.refs, ",") {
+			t.Errorf("%s = %s引用的参数为%v，应为%v", tt.name, tt.text, tie.refs, tt.refs)
+		}
+	}
+
+	for _, text := range []string{"", "0.3 *", "0.3 * KI <= 1", "FOO"} {
+		if _, err := ParseTie("KG", text, []string{"KI"}, testParameter()); err == nil {
+			t.Errorf("关联参数KG = %s应无法解析", text)
+		}
+	}
+}
@@ -2,19 +2,20 @@ package Calibration
 
 import (
 	"bufio"
-	"demo2/Confluence"
 	"demo2/Data"
-	"demo2/Evapotranspiration"
-	"demo2/Muskingum"
-	"demo2/Source"
+	"demo2/Model"
+	"demo2/Network"
+	"demo2/Objective"
 	"demo2/Watershed"
-	Runoff "demo2/runoff"
 	"fmt"
+	"io"
 	"math"
 	"math/rand"
 	"os"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -27,6 +28,12 @@ type SCEUA struct {
 	bl    []float64 // 参数下限
 	bu    []float64 // 参数上限
 
+	// 率定设置，来自scein.txt或SetSettings
+	settings    *Settings
+	fixed       []string      // 固定参数名，其值已写入parameter
+	ties        []*Tie        // 解析后的关联参数，其值随候选点计算
+	constraints []*Constraint // 解析后的约束，生成新点时舍弃不满足约束的点
+
 	// SCE控制参数
 	ngs   int // 复形数量
 	npg   int // 每个复形中的点的个数
@@ -51,16 +58,52 @@ type SCEUA struct {
 	bestf []float64   // 每次循环的最佳点函数值
 
 	// 收敛判据
-	icall  []int     // 模型调用次数
-	timeou []float64 // 函数值变化率
-	gnrng  []float64 // 参数范围归一化几何平均值
+	icall      []int        // 模型调用次数
+	timeou     []float64    // 函数值变化率
+	gnrng      []float64    // 参数范围归一化几何平均值
+	stopReason string       // 终止原因：maxn、pcento或peps
+	infeasible atomic.Int64 // 参数不合理、未运行模型的试验点数
+	fa         float64      // 参数初始值的函数值
 
 	// 模型数据
 	measuredValues  []float64 // 实测值
 	simulatedValues []float64 // 模拟值
 
 	// 文件路径
-	filePath string // 工作目录路径
+	filePath   string  // 工作目录路径
+	reportFile string  // 率定结果输出文件，为空时为工作目录下的sceout.txt
+	inputs     *Inputs // 通过SetInputs给出的模型输入，为nil时从工作目录读取
+
+	// 内存模式数据，仅在inMemory为真时加载一次
+	inMemory  bool                 // 是否在内存中计算目标函数，不经过parameter_sce.txt/Q.txt文件
+	watershed *Watershed.Watershed // 流域信息及各单元流域逐时段降雨、蒸发
+	nT        int                  // 计算时段数
+	dt        float64              // 计算时段长，h
+	parameter *Data.Parameter      // 模型参数基准值，非率定参数取该值
+	initial   []*Data.State        // 各单元流域初始状态，为nil时使用模型默认初始状态
+	network   *Network.Network     // 河网拓扑，为nil时各单元流域出流分别演算至流域出口
+	units     Data.UnitParameters  // 各单元流域参数，为nil时各单元流域共用模型参数
+
+	// 并行计算
+	nworkers int // 并行计算目标函数的协程数，仅内存模式下大于1时生效
+
+	// 预热期
+	warmup    int  // 预热期时段数，预热期不参与目标函数计算
+	spinup    int  // 预热期最大重复计算次数
+	warmupSet bool // 是否通过SetWarmup指定了预热期时段数，指定后忽略scein.txt中的设置
+	spinupSet bool // 是否通过SetSpinup指定了重复计算次数，指定后忽略scein.txt中的设置
+
+	// 缺测插补
+	fillMethod string // 降雨、蒸发缺测插补方法，见Watershed.FillZero等
+
+	// 目标函数
+	objective    Objective.Function // 目标函数，默认为1-NSE
+	objectiveSet bool               // 是否通过SetObjective指定了目标函数，指定后忽略scein.txt中的设置
+
+	// 随机数
+	seed    int64      // 随机数种子，相同输入和种子得到相同的bestx/bestf
+	seedSet bool       // 是否通过SetSeed指定了种子，指定后忽略scein.txt中的种子
+	rng     *rand.Rand // 随机数发生器，用于生成初始样本及各复形的种子
 }
 
 // NewSCEUA 创建新的SCEUA优化器实例
@@ -82,6 +125,12 @@ func NewSCEUA() *SCEUA {
 		iniflg: true,
 		iprint: false,
 		ideflt: false,
+
+		nworkers: 1,
+
+		objective: Objective.NSEObjective,
+
+		seed: time.Now().UnixNano(),
 	}
 
 	// 设置依赖参数
@@ -93,73 +142,61 @@ func NewSCEUA() *SCEUA {
 	return s
 }
 
-// Optimize 执行SCE-UA优化
-func (s *SCEUA) Optimize() {
-	s.scemain()
+// Optimize 执行SCE-UA优化，输入数据无效时返回错误且不进行优化
+func (s *SCEUA) Optimize() error {
+	return s.scemain()
 }
 
 // scemain 主要优化流程
-func (s *SCEUA) scemain() {
-	s.scein()  // 设置优化参数
+func (s *SCEUA) scemain() error {
+	// 设置优化参数
+	if err := s.scein(); err != nil {
+		return err
+	}
 	s.sceua()  // SCE-UA算法
 	s.sceout() // 输出优化结果
+	return nil
 }
 
-// scein 读取优化参数
-func (s *SCEUA) scein() {
-	// 打开输入文件
-	file, err := os.Open(s.filePath + "scein.txt")
-	if err != nil {
-		fmt.Printf("无法打开输入文件: %v\n", err)
-		return
+// scein 读取优化参数，并加载、检查模型输入数据
+// 未通过SetSettings给出率定设置时读取scein.txt
+func (s *SCEUA) scein() error {
+	if s.settings == nil {
+		settings, err := ReadSettings(s.filePath + "scein.txt")
+		if err != nil {
+			return err
+		}
+		s.applySettings(settings)
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-
-	// 读取参数个数
-	if scanner.Scan() {
-		fmt.Sscanf(scanner.Text(), "%d", &s.nopt)
+	// 读取实测值，没有observe.txt时使用按时间对齐后的observed_Q.txt
+	s.measuredValues = nil
+	if _, err := os.Stat(s.filePath + "observe.txt"); err == nil && s.inputs == nil {
+		s.measuredValues = s.ReadValues(s.filePath + "observe.txt")
 	}
 
-	// 初始化参数数组
+	// 一次性加载流域、驱动数据及参数基准值，文件模式下仅用于检查输入
+	return s.loadModelData()
+}
+
+// applySettings 设置待率定参数、SCE-UA控制参数及可选设置，可选设置不覆盖通过Set方法指定的值
+func (s *SCEUA) applySettings(settings *Settings) {
+	s.settings = settings
+
+	s.nopt = len(settings.Parameters)
 	s.xname = make([]string, s.nopt)
 	s.a = make([]float64, s.nopt)
 	s.bl = make([]float64, s.nopt)
 	s.bu = make([]float64, s.nopt)
-
-	// 读取每个变量的参数名、初始值、下限、上限
-	for i := 0; i < s.nopt; i++ {
-		if scanner.Scan() {
-			fields := strings.Fields(scanner.Text())
-			if len(fields) >= 4 {
-				s.xname[i] = fields[0]
-				fmt.Sscanf(fields[1], "%f", &s.a[i])
-				fmt.Sscanf(fields[2], "%f", &s.bl[i])
-				fmt.Sscanf(fields[3], "%f", &s.bu[i])
-			}
-		}
+	for i, r := range settings.Parameters {
+		s.xname[i], s.a[i], s.bl[i], s.bu[i] = r.Name, r.Initial, r.Lower, r.Upper
 	}
 
-	// 读取是否使用默认参数标识
-	if scanner.Scan() {
-		fmt.Sscanf(scanner.Text(), "%t", &s.ideflt)
-	}
-
-	// 如果不使用默认参数,则读取SCE控制参数
-	if s.ideflt {
-		if scanner.Scan() {
-			fmt.Sscanf(scanner.Text(), "%d %d %d %d %d",
-				&s.ngs, &s.npg, &s.nps, &s.alpha, &s.beta)
-		}
-		if scanner.Scan() {
-			fmt.Sscanf(scanner.Text(), "%d %d %f %f",
-				&s.maxn, &s.kstop, &s.pcento, &s.peps)
-		}
-		if scanner.Scan() {
-			fmt.Sscanf(scanner.Text(), "%t %t",
-				&s.iniflg, &s.iprint)
-		}
+	if c := settings.Control; c != nil {
+		s.ngs, s.npg, s.nps, s.alpha, s.beta = c.NGS, c.NPG, c.NPS, c.Alpha, c.Beta
+		s.maxn, s.kstop, s.pcento, s.peps = c.MaxN, c.KStop, c.PCento, c.PEps
+		s.iniflg, s.iprint = c.IniFlg, c.IPrint
+		s.ideflt = true
 	} else {
 		// 使用默认参数
 		s.ngs = 26
@@ -173,83 +210,413 @@ func (s *SCEUA) scein() {
 		s.peps = 0.0005
 		s.iniflg = true
 		s.iprint = false
+		s.ideflt = false
 	}
 
 	// 计算初始种群中的总点数
 	s.npt = s.ngs * s.npg
 
-	// 读取实测值
-	s.measuredValues = s.ReadValues(s.filePath + "observe.txt")
+	if settings.Seed != nil && !s.seedSet {
+		s.seed = *settings.Seed
+	}
+	if settings.Objective != "" && !s.objectiveSet {
+		if objective, err := Objective.ByName(settings.Objective); err != nil {
+			fmt.Printf("%v，使用默认目标函数%s\n", err, s.objective.Name())
+		} else {
+			s.objective = objective
+		}
+	}
+	if s.fillMethod == "" {
+		s.fillMethod = settings.Fill
+	}
+	if !s.warmupSet {
+		s.warmup = settings.Warmup
+	}
+	if !s.spinupSet {
+		s.spinup = settings.Spinup
+	}
+}
+
+// loadModelData 一次性读取流域信息、降雨蒸发、参数基准值及初始状态，任一输入无效时返回错误
+func (s *SCEUA) loadModelData() error {
+	in := s.inputs
+	if in == nil {
+		var err error
+		if in, err = s.readInputs(); err != nil {
+			return err
+		}
+	} else {
+		// 给出的输入不对应工作目录中的文件，只能在内存中计算
+		s.inMemory = true
+	}
+
+	s.watershed = in.Watershed
+	s.nT = in.IO.Nrows
+	s.dt = stepHours(in.IO)
+	if s.measuredValues == nil {
+		s.measuredValues = in.IO.Q
+	}
+	if len(s.measuredValues) == 0 {
+		return fmt.Errorf("无法从observe.txt或observed_Q.txt读取实测值")
+	}
+	if len(s.measuredValues) != s.nT {
+		return fmt.Errorf("实测值%d个与计算时段数%d不一致", len(s.measuredValues), s.nT)
+	}
+
+	// 模型参数中的非率定参数（如C、EX、KE）作为基准值，复制后再写入固定参数
+	parameter := *in.Parameter
+	s.parameter = &parameter
+	s.initial = in.Initial
+	s.network = in.Network
+	s.units = in.Units
+
+	if err := s.parseParameters(); err != nil {
+		return err
+	}
+
+	// 以参数初始值试算一次，检查参数是否合理、模型能否运行
+	trial, units := s.applyParameters(s.a)
+	_, err := s.simulate(s.watershed, trial, units, s.initial, s.network, s.nT)
+	return err
+}
+
+// readInputs 从工作目录读取流域信息、降雨蒸发、模型参数、初始状态、河网及单元流域参数
+func (s *SCEUA) readInputs() (*Inputs, error) {
+	in := &Inputs{Watershed: &Watershed.Watershed{}, IO: &Watershed.IO{FillMethod: s.fillMethod}, Parameter: &Data.Parameter{}}
+	if err := in.Watershed.ReadFromFile(s.filePath); err != nil {
+		return nil, fmt.Errorf("无法读取流域信息: %w", err)
+	}
+	if err := in.IO.ReadFromFile(s.filePath); err != nil {
+		return nil, fmt.Errorf("无法读取降雨蒸发数据: %w", err)
+	}
+	if err := in.Watershed.Calculate(in.IO); err != nil {
+		return nil, err
+	}
+	if in.IO.NumFilled > 0 {
+		fmt.Printf("降雨、蒸发中共插补%d个缺测值\n", in.IO.NumFilled)
+	}
+	if err := in.Parameter.ReadFromFile(s.filePath); err != nil {
+		return nil, fmt.Errorf("无法读取模型参数: %w", err)
+	}
+
+	var err error
+	if in.Initial, err = readInitialStates(s.filePath, in.Watershed.GetnW()); err != nil {
+		return nil, err
+	}
+	if in.Network, err = readNetwork(s.filePath); err != nil {
+		return nil, err
+	}
+	if in.Units, err = readUnitParameters(s.filePath, in.Watershed.GetnW()); err != nil {
+		return nil, err
+	}
+	return in, nil
 }
 
-// sceout 输出优化结果
+// parseParameters 检查待率定参数名，设置固定参数，解析关联参数及参数间约束
+// 参数名须为模型参数名，“参数名@分组”形式的参数作用于unitparameter.txt中该分组的各单元流域
+func (s *SCEUA) parseParameters() error {
+	defined := make(map[string]string) // 已定义的参数名及其类别
+	define := func(name, kind string) error {
+		param, group, grouped := strings.Cut(name, "@")
+		switch {
+		case !Data.IsParameter(param):
+			return fmt.Errorf("%s%s不是模型参数，可选%s", kind, name, strings.Join(Data.ParameterNames(), "、"))
+		case grouped && group == "":
+			return fmt.Errorf("%s%s的分组名为空", kind, name)
+		case grouped && !s.units.HasGroup(group):
+			return fmt.Errorf("%s%s的分组%s在%s中不存在", kind, name, group, Data.UnitParameterFile)
+		case defined[name] != "":
+			return fmt.Errorf("参数%s重复定义为%s和%s", name, defined[name], kind)
+		case grouped && !s.inMemory:
+			fmt.Printf("分组参数%s仅在内存模式下生效\n", name)
+		}
+		defined[name] = kind
+		return nil
+	}
+
+	for _, name := range s.xname {
+		if err := define(name, "待率定参数"); err != nil {
+			return err
+		}
+		// 河网演算中未给出的KE、XE取流域参数，此时流域参数仍起作用
+		if s.units.SetForAll(name) && !(s.network != nil && (name == "KE" || name == "XE")) {
+			return fmt.Errorf("待率定参数%s在%s中已为每个单元流域给出，率定的流域参数不起作用，可改为按分组率定", name, Data.UnitParameterFile)
+		}
+	}
+
+	s.fixed = nil
+	for _, name := range sortedKeys(s.settings.Fixed) {
+		if strings.Contains(name, "@") {
+			return fmt.Errorf("固定参数%s不能带分组，单元流域参数在%s中给出", name, Data.UnitParameterFile)
+		}
+		if err := define(name, "固定参数"); err != nil {
+			return err
+		}
+		s.parameter.Set(name, s.settings.Fixed[name])
+		s.fixed = append(s.fixed, name)
+	}
+
+	s.ties = nil
+	for _, name := range sortedKeys(s.settings.Tied) {
+		t, err := ParseTie(name, s.settings.Tied[name], s.xname, s.parameter)
+		if err != nil {
+			return err
+		}
+		if err := define(t.Name, "关联参数"); err != nil {
+			return err
+		}
+		s.ties = append(s.ties, t)
+	}
+	for _, t := range s.ties {
+		for _, ref := range t.refs {
+			if defined[ref] == "关联参数" {
+				return fmt.Errorf("关联参数%s不能引用关联参数%s", t.Name, ref)
+			}
+		}
+	}
+
+	// 约束中的关联参数取候选点对应的值，参数初始值须满足各约束
+	s.constraints = nil
+	for _, text := range s.settings.Constraints {
+		c, err := ParseConstraint(text, s.names(), s.parameter)
+		if err != nil {
+			return err
+		}
+		if !c.Satisfied(s.extend(s.a)) {
+			return fmt.Errorf("参数初始值不满足约束“%s”", text)
+		}
+		s.constraints = append(s.constraints, c)
+	}
+	return nil
+}
+
+// sortedKeys 返回按名称排序的键
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// names 返回待率定参数名及关联参数名
+func (s *SCEUA) names() []string {
+	names := append([]string(nil), s.xname...)
+	for _, t := range s.ties {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+// extend 返回候选点x之后接各关联参数值的向量，与names对应
+func (s *SCEUA) extend(x []float64) []float64 {
+	if len(s.ties) == 0 {
+		return x
+	}
+	xe := append([]float64(nil), x...)
+	for _, t := range s.ties {
+		xe = append(xe, t.Value(x))
+	}
+	return xe
+}
+
+// sceout 输出优化结果到控制台及sceout.txt
 func (s *SCEUA) sceout() {
+	s.fa = s.functn(s.a)
+
 	// 控制台输出
-	fmt.Println("===================参数初始值及上下限===================")
+	s.writeSummary(os.Stdout)
+
+	// 文件输出
+	fileName := s.reportFile
+	if fileName == "" {
+		fileName = s.filePath + "sceout.txt"
+	}
+	file, err := os.Create(fileName)
+	if err != nil {
+		fmt.Printf("无法创建输出文件: %v\n", err)
+		return
+	}
+	defer file.Close()
 
-	fmt.Print("参数名：")
+	writer := bufio.NewWriter(file)
+	s.writeReport(writer)
+	fmt.Fprintln(writer, "[summary]")
+	s.writeSummary(writer)
+	if err := writer.Flush(); err != nil {
+		fmt.Printf("无法写入输出文件: %v\n", err)
+	}
+}
+
+// writeReport 输出机器可读的率定结果，各节以[节名]开头，节内为空白分隔的“键 值”或表格
+func (s *SCEUA) writeReport(w io.Writer) {
+	best := s.bestx[len(s.bestx)-1]
+
+	fmt.Fprintln(w, "[result]")
+	fmt.Fprintf(w, "objective %s\n", Objective.Expression(s.objective))
+	fmt.Fprintf(w, "seed %d\n", s.seed)
+	fmt.Fprintf(w, "stop %s\n", s.stopReason)
+	fmt.Fprintf(w, "nloop %d\n", len(s.icall))
+	fmt.Fprintf(w, "icall %d\n", lastInt(s.icall))
+	fmt.Fprintf(w, "initial_f %g\n", s.fa)
+	fmt.Fprintf(w, "best_f %g\n", s.bestf[len(s.bestf)-1])
+	fmt.Fprintf(w, "infeasible %d\n", s.infeasible.Load())
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "[control]")
+	fmt.Fprintf(w, "ngs %d\nnpg %d\nnps %d\nalpha %d\nbeta %d\nnpt %d\n",
+		s.ngs, s.npg, s.nps, s.alpha, s.beta, s.npt)
+	fmt.Fprintf(w, "maxn %d\nkstop %d\npcento %g\npeps %g\niniflg %t\n",
+		s.maxn, s.kstop, s.pcento, s.peps, s.iniflg)
+	fmt.Fprintf(w, "warmup %d\nspinup %d\n", s.warmup, s.spinup)
+	fmt.Fprintln(w)
+
+	if len(s.constraints) > 0 {
+		fmt.Fprintln(w, "[constraints]")
+		fmt.Fprintln(w, "rejected constraint")
+		for _, c := range s.constraints {
+			fmt.Fprintf(w, "%d %s\n", c.Rejected(), c.Text)
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintln(w, "[parameters]")
+	fmt.Fprintln(w, "name initial lower upper best")
+	for i, name := range s.xname {
+		fmt.Fprintf(w, "%s %g %g %g %g\n", name, s.a[i], s.bl[i], s.bu[i], best[i])
+	}
+	fmt.Fprintln(w)
+
+	if len(s.fixed) > 0 {
+		fmt.Fprintln(w, "[fixed]")
+		fmt.Fprintln(w, "name value")
+		for _, name := range s.fixed {
+			value, _ := s.parameter.Get(name)
+			fmt.Fprintf(w, "%s %g\n", name, value)
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(s.ties) > 0 {
+		fmt.Fprintln(w, "[tied]")
+		fmt.Fprintln(w, "name best expression")
+		for _, t := range s.ties {
+			fmt.Fprintf(w, "%s %g %s\n", t.Name, t.Value(best), t.Text)
+		}
+		fmt.Fprintln(w)
+	}
+
+	// 第0次为初始样本排序后的最优点
+	fmt.Fprintln(w, "[history]")
+	fmt.Fprintln(w, "loop icall timeou gnrng bestf")
+	for i := range s.icall {
+		fmt.Fprintf(w, "%d %d %g %g %g\n", i+1, s.icall[i], s.timeou[i], s.gnrng[i], s.bestf[i+1])
+	}
+	fmt.Fprintln(w)
+}
+
+// writeSummary 输出便于阅读的率定结果
+func (s *SCEUA) writeSummary(w io.Writer) {
+	fmt.Fprintln(w, "===================参数初始值及上下限===================")
+
+	fmt.Fprint(w, "参数名：")
 	for _, name := range s.xname {
-		fmt.Printf("%s  ", name)
+		fmt.Fprintf(w, "%s  ", name)
 	}
-	fmt.Println()
+	fmt.Fprintln(w)
 
-	fmt.Print("参数初始值：")
+	fmt.Fprint(w, "参数初始值：")
 	for _, val := range s.a {
-		fmt.Printf("%f  ", val)
+		fmt.Fprintf(w, "%f  ", val)
 	}
-	fmt.Println()
+	fmt.Fprintln(w)
 
-	fmt.Print("参数下限：")
+	fmt.Fprint(w, "参数下限：")
 	for _, val := range s.bl {
-		fmt.Printf("%f  ", val)
+		fmt.Fprintf(w, "%f  ", val)
 	}
-	fmt.Println()
+	fmt.Fprintln(w)
 
-	fmt.Print("参数上限：")
+	fmt.Fprint(w, "参数上限：")
 	for _, val := range s.bu {
-		fmt.Printf("%f  ", val)
+		fmt.Fprintf(w, "%f  ", val)
+	}
+	fmt.Fprintln(w)
+
+	if len(s.fixed) > 0 {
+		fmt.Fprint(w, "固定参数：")
+		for _, name := range s.fixed {
+			value, _ := s.parameter.Get(name)
+			fmt.Fprintf(w, "%s=%g  ", name, value)
+		}
+		fmt.Fprintln(w)
 	}
-	fmt.Println()
 
-	fmt.Printf("初始参数函数值：%f\n", s.functn(s.a))
+	fmt.Fprintf(w, "初始参数函数值：%f\n", s.fa)
 
 	// 打印优化结果
-	fmt.Println("===================SCE-UA搜索的结果===================")
+	fmt.Fprintln(w, "===================SCE-UA搜索的结果===================")
 
-	fmt.Println("最优点:")
+	fmt.Fprintln(w, "最优点:")
 	for _, name := range s.xname {
-		fmt.Printf("%s  ", name)
+		fmt.Fprintf(w, "%s  ", name)
 	}
-	fmt.Println()
+	fmt.Fprintln(w)
 
 	for _, val := range s.bestx[len(s.bestx)-1] {
-		fmt.Printf("%f  ", val)
+		fmt.Fprintf(w, "%f  ", val)
 	}
-	fmt.Println()
+	fmt.Fprintln(w)
 
-	fmt.Printf("最优函数值: %f\n", s.bestf[len(s.bestf)-1])
+	for _, t := range s.ties {
+		fmt.Fprintf(w, "关联参数%s = %s = %f\n", t.Name, t.Text, t.Value(s.bestx[len(s.bestx)-1]))
+	}
+
+	fmt.Fprintf(w, "最优函数值: %f\n", s.bestf[len(s.bestf)-1])
 
-	fmt.Printf("%d次洗牌演化的最优点函数值：\n", len(s.bestf))
+	fmt.Fprintf(w, "%d次洗牌演化的最优点函数值：\n", len(s.bestf))
 	for _, val := range s.bestf {
-		fmt.Printf("%f  ", val)
+		fmt.Fprintf(w, "%f  ", val)
 	}
-	fmt.Println()
+	fmt.Fprintln(w)
 
-	// 文件输出
-	file, err := os.Create(s.filePath + "sceout.txt")
-	if err != nil {
-		fmt.Printf("无法创建输出文件: %v\n", err)
-		return
+	fmt.Fprintln(w, "目标函数调用次数  函数值变化率  参数变化范围")
+	for i := range s.icall {
+		fmt.Fprintf(w, "      %d            %f        %f\n", s.icall[i], s.timeou[i], s.gnrng[i])
 	}
-	defer file.Close()
 
-	writer := bufio.NewWriter(file)
-	// ... 将上述相同的输出内容写入文件 ...
-	writer.Flush()
+	fmt.Fprintf(w, "终止原因: %s\n", stopReasons[s.stopReason])
+	fmt.Fprintf(w, "参数不合理的试验点数: %d\n", s.infeasible.Load())
+	for _, c := range s.constraints {
+		fmt.Fprintf(w, "不满足约束“%s”而舍弃的点数: %d\n", c.Text, c.Rejected())
+	}
+	fmt.Fprintf(w, "目标函数: %s\n", Objective.Expression(s.objective))
+	fmt.Fprintf(w, "随机数种子: %d\n", s.seed)
+}
+
+// stopReasons 优化终止原因的说明
+var stopReasons = map[string]string{
+	"maxn":   "超过最大试验次数",
+	"pcento": "最近kstop次循环中函数值变化率小于阈值",
+	"peps":   "种群收敛到预先指定的小参数空间",
+}
+
+// lastInt 返回整数序列的最后一个值，序列为空时返回0
+func lastInt(values []int) int {
+	if len(values) == 0 {
+		return 0
+	}
+	return values[len(values)-1]
 }
 
 // ReadValues 从文件中读取数值
 func (s *SCEUA) ReadValues(fileName string) []float64 {
+	return ReadValues(fileName)
+}
+
+// ReadValues 从文件中逐行读取数值
+func ReadValues(fileName string) []float64 {
 	file, err := os.Open(fileName)
 	if err != nil {
 		fmt.Printf("无法打开文件 %s: %v\n", fileName, err)
@@ -257,15 +624,25 @@ func (s *SCEUA) ReadValues(fileName string) []float64 {
 	}
 	defer file.Close()
 
+	// 每行取最后一个数值，行首可为时间戳；空行、NaN及-9999视为缺测，以NaN表示，文件末尾的空行忽略
 	var values []float64
+	n := 0 // 最后一个非空行之前的数值个数
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
-		var val float64
-		fmt.Sscanf(scanner.Text(), "%f", &val)
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			values = append(values, math.NaN())
+			continue
+		}
+		val, err := Watershed.ParseValue(fields[len(fields)-1])
+		if err != nil {
+			fmt.Printf("%s中无法解析的数值按缺测处理: %v\n", fileName, err)
+		}
 		values = append(values, val)
+		n = len(values)
 	}
 
-	return values
+	return values[:n]
 }
 
 // sceua SCE-UA主优化算法
@@ -281,11 +658,17 @@ func (s *SCEUA) sceua() {
 	}
 	xf := make([]float64, s.npt) // 种群中点的函数值
 
-	cx := make([][]float64, s.npg) // 复形中点的坐标
-	for i := range cx {
-		cx[i] = make([]float64, s.nopt)
+	cx := make([][][]float64, s.ngs) // 各复形中点的坐标
+	cf := make([][]float64, s.ngs)   // 各复形中点的函数值
+	for k := 0; k < s.ngs; k++ {
+		cx[k] = make([][]float64, s.npg)
+		for i := range cx[k] {
+			cx[k][i] = make([]float64, s.nopt)
+		}
+		cf[k] = make([]float64, s.npg)
 	}
-	cf := make([]float64, s.npg) // 复形中点的函数值
+	ncall := make([]int, s.ngs)       // 各复形演化中的模型调用次数
+	rngs := make([]*rand.Rand, s.ngs) // 各复形演化使用的随机数发生器
 
 	xnstd := make([]float64, s.nopt) // 种群中参数的标准差
 	for i := range xnstd {
@@ -297,6 +680,14 @@ func (s *SCEUA) sceua() {
 		bound[j] = s.bu[j] - s.bl[j]
 	}
 
+	// 由种子初始化随机数发生器，保证相同输入和种子的结果可复现
+	s.rng = rand.New(rand.NewSource(s.seed))
+	s.infeasible.Store(0)
+	for _, c := range s.constraints {
+		c.rejected.Store(0)
+	}
+	fmt.Printf("随机数种子: %d\n", s.seed)
+
 	icall := 0        // 模型调用次数
 	timeou := 10000.0 // 函数值变化率
 	gnrng := 10000.0  // 参数范围归一化几何平均值
@@ -314,16 +705,25 @@ func (s *SCEUA) sceua() {
 	for icall < s.maxn && timeou > s.pcento && gnrng > s.peps {
 		nloop++
 
-		// 对每个复形进行独立演化
+		// 各复形的随机数种子按顺序生成，保证并行演化的结果与协程调度无关
 		for k := 0; k < s.ngs; k++ {
+			rngs[k] = rand.New(rand.NewSource(s.rng.Int63()))
+			ncall[k] = 0
+		}
+
+		// 对每个复形进行独立演化，各复形占用种群中互不重叠的点，可并行计算
+		s.parallelFor(s.ngs, func(k int) {
 			// 4. 划分复形群体
-			s.Partition2Complexes(k, x, xf, cx, cf)
+			s.Partition2Complexes(k, x, xf, cx[k], cf[k])
 
 			// 5. 复形演化
-			s.cce(cx, cf, xnstd, &icall)
+			s.cce(rngs[k], cx[k], cf[k], xnstd, &ncall[k])
 
 			// 6. 复形牌
-			s.ShuffleComplexes(k, x, xf, cx, cf)
+			s.ShuffleComplexes(k, x, xf, cx[k], cf[k])
+		})
+		for k := 0; k < s.ngs; k++ {
+			icall += ncall[k]
 		}
 
 		// 3. 样本点排序
@@ -334,6 +734,16 @@ func (s *SCEUA) sceua() {
 
 		fmt.Printf("      %d            %f        %f\n", icall, timeou, gnrng)
 	}
+
+	// 记录终止原因
+	switch {
+	case icall >= s.maxn:
+		s.stopReason = "maxn"
+	case timeou <= s.pcento:
+		s.stopReason = "pcento"
+	default:
+		s.stopReason = "peps"
+	}
 }
 
 // GenerateSample 在参数空间内生成初始样本点
@@ -352,39 +762,77 @@ func (s *SCEUA) GenerateSample(x [][]float64, xf []float64, icall *int) {
 	}
 
 	// 计算函数值
-	for i := 0; i < s.npt; i++ {
+	s.parallelFor(s.npt, func(i int) {
 		xf[i] = s.functn(x[i])
-		*icall++
+	})
+	*icall += s.npt
+}
+
+// parallelFor 使用协程池对0~n-1逐个调用fn，协程数为1时顺序计算
+// 仅内存模式下并行，文件模式共用parameter_sce.txt和Q.txt，只能顺序计算
+func (s *SCEUA) parallelFor(n int, fn func(i int)) {
+	nworkers := min(s.nworkers, n)
+	if !s.inMemory || nworkers <= 1 {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
 	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < nworkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
 }
 
+// 生成新点的最大尝试次数，可行域过小时超过该次数仍不满足约束则取已知的可行点
+const maxTries = 100000
+
 // getpnt 在可行区域内生成一个随机点
 func (s *SCEUA) getpnt(snew []float64) {
-	rand.Seed(time.Now().UnixNano())
 	ibound := true
 
-	for ibound {
+	for tries := 0; ibound; tries++ {
+		if tries == maxTries {
+			copy(snew, s.a)
+			return
+		}
 		for j := 0; j < s.nopt; j++ {
-			snew[j] = s.bl[j] + rand.Float64()*(s.bu[j]-s.bl[j])
+			snew[j] = s.bl[j] + s.rng.Float64()*(s.bu[j]-s.bl[j])
 		}
 		s.chkcst(snew, &ibound)
 	}
 }
 
-// getpntNormal 根据正态分布生成新点
-func (s *SCEUA) getpntNormal(xi, std, snew []float64) {
-	rand.Seed(time.Now().UnixNano())
+// getpntNormal 根据正态分布生成新点，xi须为可行点
+func (s *SCEUA) getpntNormal(rng *rand.Rand, xi, std, snew []float64) {
 	ibound := true
 
-	for ibound {
+	for tries := 0; ibound; tries++ {
+		if tries == maxTries {
+			copy(snew, xi)
+			return
+		}
 		for j := 0; j < s.nopt; j++ {
-			snew[j] = rand.NormFloat64()*std[j] + xi[j]
+			snew[j] = rng.NormFloat64()*std[j] + xi[j]
 		}
 		s.chkcst(snew, &ibound)
 	}
 }
 
-// chkcst 检查参数约束条件
+// chkcst 检查参数上下限及参数间约束，不满足约束的点计入该约束的舍弃点数
 func (s *SCEUA) chkcst(xx []float64, ibound *bool) {
 	*ibound = false
 	for i := 0; i < s.nopt; i++ {
@@ -393,6 +841,14 @@ func (s *SCEUA) chkcst(xx []float64, ibound *bool) {
 			return
 		}
 	}
+	xe := s.extend(xx)
+	for _, c := range s.constraints {
+		if !c.Satisfied(xe) {
+			c.rejected.Add(1)
+			*ibound = true
+			return
+		}
+	}
 }
 
 // RankPoints 对样本点按函数值升序排序
@@ -446,7 +902,8 @@ func (s *SCEUA) ShuffleComplexes(k int, x [][]float64, xf []float64, cx [][]floa
 }
 
 // cce 复形演化算法
-func (s *SCEUA) cce(cx [][]float64, cf []float64, xnstd []float64, icall *int) {
+// rng为该复形独占的随机数发生器，icall为该复形独占的调用计数，可与其他复形并行演化
+func (s *SCEUA) cce(rng *rand.Rand, cx [][]float64, cf []float64, xnstd []float64, icall *int) {
 	// 初始化局部变量
 	ss := make([][]float64, s.nps) // 当前单纯形中点的坐标
 	for i := range ss {
@@ -462,7 +919,7 @@ func (s *SCEUA) cce(cx [][]float64, cf []float64, xnstd []float64, icall *int) {
 	// 遍历beta次
 	for ibeta := 0; ibeta < s.beta; ibeta++ {
 		// 选择父辈群体
-		lcs := s.selectParents(s.npg, s.nps)
+		lcs := s.selectParents(rng, s.npg, s.nps)
 
 		// 构建单纯形
 		for i := 0; i < s.nps; i++ {
@@ -495,7 +952,7 @@ func (s *SCEUA) cce(cx [][]float64, cf []float64, xnstd []float64, icall *int) {
 			s.chkcst(snew, &ibound)
 
 			if ibound {
-				s.getpntNormal(sb, xnstd, snew)
+				s.getpntNormal(rng, sb, xnstd, snew)
 			}
 
 			// 计算新点函数值
@@ -507,20 +964,23 @@ func (s *SCEUA) cce(cx [][]float64, cf []float64, xnstd []float64, icall *int) {
 				copy(ss[s.nps-1], snew)
 				sf[s.nps-1] = fnew
 			} else {
-				// 收缩步骤
+				// 收缩步骤，非线性约束下收缩点可能不可行，此时直接突变
 				for j := 0; j < s.nopt; j++ {
 					snew[j] = (ce[j] + sw[j]) / 2.0
 				}
 
-				fnew = s.functn(snew)
-				*icall++
+				s.chkcst(snew, &ibound)
+				if !ibound {
+					fnew = s.functn(snew)
+					*icall++
+				}
 
-				if fnew < fw {
+				if !ibound && fnew < fw {
 					copy(ss[s.nps-1], snew)
 					sf[s.nps-1] = fnew
 				} else {
 					// 突变步骤
-					s.getpntNormal(sb, xnstd, snew)
+					s.getpntNormal(rng, sb, xnstd, snew)
 					fnew = s.functn(snew)
 					*icall++
 
@@ -545,7 +1005,7 @@ func (s *SCEUA) cce(cx [][]float64, cf []float64, xnstd []float64, icall *int) {
 }
 
 // selectParents 从复形中选择父代点
-func (s *SCEUA) selectParents(npg, nps int) []int {
+func (s *SCEUA) selectParents(rng *rand.Rand, npg, nps int) []int {
 	// 计算每个点的权重
 	wts := make([]float64, npg)
 	for i := 0; i < npg; i++ {
@@ -553,7 +1013,6 @@ func (s *SCEUA) selectParents(npg, nps int) []int {
 	}
 
 	// 随机扰动权重
-	rand.Seed(time.Now().UnixNano())
 	vals := make([]struct {
 		idx int
 		val float64
@@ -561,7 +1020,7 @@ func (s *SCEUA) selectParents(npg, nps int) []int {
 
 	for i := 0; i < npg; i++ {
 		vals[i].idx = i
-		vals[i].val = math.Pow(rand.Float64(), 1.0/wts[i])
+		vals[i].val = math.Pow(rng.Float64(), 1.0/wts[i])
 	}
 
 	// 按扰动后的权重排序
@@ -587,6 +1046,9 @@ func (s *SCEUA) CheckConvergence(x [][]float64, xnstd []float64, bound []float64
 	if *icall >= s.maxn {
 		fmt.Printf("经过%d次洗牌演化，优化搜索已经终止，因为超过了最大试验次数%d次的限制\n",
 			nloop, s.maxn)
+		s.icall = append(s.icall, *icall)
+		s.timeou = append(s.timeou, *timeou)
+		s.gnrng = append(s.gnrng, *gnrng)
 		return
 	}
 
@@ -600,7 +1062,15 @@ func (s *SCEUA) CheckConvergence(x [][]float64, xnstd []float64, bound []float64
 		denomi := sum / float64(s.kstop)
 
 		// 计算变化率
-		*timeou = math.Abs(s.bestf[len(s.bestf)-1]-s.bestf[len(s.bestf)-s.kstop]) / denomi
+		// 最佳函数值均为零时没有变化；为正无穷时变化率无意义，视为未收敛
+		switch {
+		case denomi == 0:
+			*timeou = 0
+		case math.IsInf(denomi, 0):
+			*timeou = math.Inf(1)
+		default:
+			*timeou = math.Abs(s.bestf[len(s.bestf)-1]-s.bestf[len(s.bestf)-s.kstop]) / denomi
+		}
 
 		if *timeou < s.pcento {
 			fmt.Printf("最佳点在最近%d次循环中函数值变化率小于阈值%f\n",
@@ -654,150 +1124,239 @@ func (s *SCEUA) CheckConvergence(x [][]float64, xnstd []float64, bound []float64
 	s.gnrng = append(s.gnrng, *gnrng)
 }
 
+// 参数不合理时的罚函数基准值，远大于各目标函数的正常取值
+const infeasiblePenalty = 1e10
+
+// 文件模式下候选参数的输出文件，parameter.txt只作为参数基准值读取，不被改写
+const CandidateFile = "parameter_sce.txt"
+
 // functn 计算目标函数值
 func (s *SCEUA) functn(x []float64) float64 {
+	// 参数不合理（如WM < UM+LM、KI+KG ≥ 1）的点不运行模型，函数值为罚函数基准值加超出可行域的量，
+	// 使种群向可行域演化
+	if excess := s.infeasibility(x); excess > 0 {
+		s.infeasible.Add(1)
+		return infeasiblePenalty + excess
+	}
+
+	if s.inMemory {
+		return s.evaluate(x)
+	}
+
 	// 1. 前处理
 	s.PreProcessing(x)
 
-	// 2. 运行模型
-	s.RunModel()
+	// 2. 运行模型，模型无法运行时该点的函数值为正无穷
+	if err := s.RunModel(); err != nil {
+		fmt.Printf("模型运行失败: %v\n", err)
+		return math.Inf(1)
+	}
 
 	// 3. 后处理
-	return s.PostProcessing()
+	return finite(s.PostProcessing())
 }
 
-// PreProcessing 前处理，将参数写入模型输入文件
+// finite 将无定义的目标函数值（NaN）视为正无穷，保证排序及比较有意义
+func finite(f float64) float64 {
+	if math.IsNaN(f) {
+		return math.Inf(1)
+	}
+	return f
+}
+
+// PreProcessing 前处理，将参数值按参数名映射到模型参数后写入候选参数文件parameter_sce.txt
 func (s *SCEUA) PreProcessing(x []float64) {
-	// 打开模板文件
-	fin, err := os.Open(s.filePath + "parameter.tpl")
+	parameter, _ := s.applyParameters(x)
+	if err := parameter.WriteFile(s.filePath + CandidateFile); err != nil {
+		fmt.Printf("无法写入参数文件: %v\n", err)
+	}
+}
+
+// RunModel 以候选参数文件中的参数运行水文模型，流量过程输出到Q.txt
+func (s *SCEUA) RunModel() error {
+	// 调用实际的水文模型
+	path := s.filePath
+
+	// 读取流域分块信息
+	var watershed Watershed.Watershed
+	if err := watershed.ReadFromFile(path); err != nil {
+		return err
+	}
+
+	var parameter Data.Parameter
+	if err := parameter.ReadFile(path + CandidateFile); err != nil {
+		return err
+	}
+
+	var io Watershed.IO
+	io.FillMethod = s.fillMethod
+	if err := io.ReadFromFile(path); err != nil {
+		return err
+	}
+	if err := watershed.Calculate(&io); err != nil {
+		return err
+	}
+
+	initial, err := readInitialStates(path, watershed.GetnW())
 	if err != nil {
-		fmt.Printf("无法打开参数模板文件: %v\n", err)
-		return
+		return err
+	}
+	network, err := readNetwork(path)
+	if err != nil {
+		return err
+	}
+	units, err := readUnitParameters(path, watershed.GetnW())
+	if err != nil {
+		return err
+	}
+	var unitParameters []*Data.Parameter
+	if units != nil {
+		unitParameters = units.Resolve(&parameter, nil)
+	}
+	s.dt = stepHours(&io)
+	if io.MQ, err = s.simulate(&watershed, &parameter, unitParameters, initial, network, io.Nrows); err != nil {
+		return err
 	}
-	defer fin.Close()
 
-	// 创建输出文件
-	fout, err := os.Create(s.filePath + "parameter.txt")
+	// 输出流域出口断面流量过程到文本Q.txt中
+	return io.WriteToFile(path)
+}
+
+// evaluate 内存模式下计算目标函数值，参数直接映射到Data.Parameter后在内存中模拟
+// 每次调用创建独立的模型实例和状态，只读共享流域及实测数据
+func (s *SCEUA) evaluate(x []float64) float64 {
+	parameter, units := s.applyParameters(x)
+
+	// 模拟值为局部变量，多个协程可同时计算；输入已在loadModelData中检查过
+	simulatedValues, err := s.simulate(s.watershed, parameter, units, s.initial, s.network, s.nT)
 	if err != nil {
-		fmt.Printf("无法创建参数文件: %v\n", err)
-		return
+		return math.Inf(1)
 	}
-	defer fout.Close()
 
-	scanner := bufio.NewScanner(fin)
-	for scanner.Scan() {
-		parameter := scanner.Text()
-
-		// 查找参数名是否在待优化参数中
-		found := false
-		for i, name := range s.xname {
-			if parameter == name {
-				fmt.Fprintf(fout, "%f\n", x[i])
-				found = true
-				break
-			}
+	return finite(s.objective.Evaluate(s.afterWarmup(simulatedValues), s.afterWarmup(s.measuredValues)))
+}
+
+// infeasibility 返回参数值映射后的流域参数及各单元流域参数超出可行域的总量，均合理时为0
+func (s *SCEUA) infeasibility(x []float64) float64 {
+	parameter, units := s.applyParameters(x)
+	excess := parameter.Infeasibility()
+	for _, p := range units {
+		excess += p.Infeasibility()
+	}
+	return excess
+}
+
+// applyParameters 将待率定参数及关联参数的值按参数名映射到流域参数及各单元流域参数，“参数名@分组”形式的参数作用于该分组的各单元流域
+// 未给出单元流域参数时返回的单元流域参数为nil
+func (s *SCEUA) applyParameters(x []float64) (*Data.Parameter, []*Data.Parameter) {
+	parameter := *s.parameter
+	var groups map[string]map[string]float64
+	set := func(name string, value float64) {
+		param, group, ok := strings.Cut(name, "@")
+		if !ok {
+			parameter.Set(name, value)
+			return
 		}
+		if groups == nil {
+			groups = make(map[string]map[string]float64)
+		}
+		if groups[group] == nil {
+			groups[group] = make(map[string]float64)
+		}
+		groups[group][param] = value
+	}
+	for i, name := range s.xname {
+		set(name, x[i])
+	}
+	for _, t := range s.ties {
+		set(t.Name, t.Value(x))
+	}
 
-		// 如果不是待优化参数，直接写入
-		if !found {
-			fmt.Fprintln(fout, parameter)
+	if s.units == nil {
+		return &parameter, nil
+	}
+	return &parameter, s.units.Resolve(&parameter, groups)
+}
+
+// simulate 运行新安江模型，返回流域出口断面流量过程
+// 初始张力水及自由水蓄量限制在候选参数对应的容量内；给出河网时经河网演算至流域出口
+func (s *SCEUA) simulate(watershed *Watershed.Watershed, parameter *Data.Parameter, units []*Data.Parameter, initial []*Data.State, network *Network.Network, nT int) ([]float64, error) {
+	model, err := Model.NewModel(watershed, parameter)
+	if err != nil {
+		return nil, err
+	}
+	if err := model.SetDt(s.dt); err != nil {
+		return nil, err
+	}
+	if err := model.SetNetwork(network); err != nil {
+		return nil, err
+	}
+	if err := model.SetUnitParameters(units); err != nil {
+		return nil, err
+	}
+	model.SetWarmup(s.warmup)
+	model.SetSpinup(s.spinup, 0)
+	for w, state := range initial {
+		s := *state
+		if units != nil {
+			s.ClampTo(units[w])
+		} else {
+			s.ClampTo(parameter)
 		}
+		model.SetInitialState(w, &s)
 	}
+	result, err := model.Run(nT)
+	if err != nil {
+		return nil, err
+	}
+	return result.Q, nil
 }
 
-// RunModel 运行水文模型
-func (s *SCEUA) RunModel() {
-	// 调用实际的水文模型
-	// 获取可执行文件所在路径
-	path := "/Users/baogy/goProject/owner/demo2/datas/"
+// stepHours 返回数据文件或time.txt给出的时段长，h，均未给出时为24h
+func stepHours(io *Watershed.IO) float64 {
+	if io.Step > 0 {
+		return io.Step.Hours()
+	}
+	return 24.0
+}
 
-	// 读取流域分块信息
-	var watershed Watershed.Watershed
-	watershed.ReadFromFile(path)
+// readInitialStates 读取工作目录下的初始状态文件，文件不存在时返回nil
+func readInitialStates(filePath string, nw int) ([]*Data.State, error) {
+	if _, err := os.Stat(filePath + Data.InitialStateFile); err != nil {
+		return nil, nil
+	}
 
-	var parameter Data.Parameter
-	parameter.ReadFromFile(path)
+	states, err := Data.ReadInitialStates(filePath, nw)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取初始状态: %w", err)
+	}
+	return states, nil
+}
 
-	var io Watershed.IO
-	io.ReadFromFile(path)
-	watershed.Calculate(&io)
-	// 首先进行参数率定
-
-	// 设置模型实例
-
-	// 创建状态数组
-	nw := watershed.GetnW()
-	states := make([]*Data.State, nw)
-	for i := 0; i < nw; i++ {
-		states[i] = &Data.State{
-			WU: 5,
-			WL: 20,
-			WD: 30,
-			W:  55,
-			Dt: 24.0,
-		}
-		states[i].ReadFromFile(path)
-	}
-
-	//流域蒸散发
-	var evapotranspiration Evapotranspiration.Evapotranspiration
-	evapotranspiration.WL = 20
-	evapotranspiration.WU = 5
-	evapotranspiration.SetParmameter(&parameter)
-
-	//流域产流
-	var runoff Runoff.Runoff
-	runoff.WU = 5
-	runoff.WL = 20
-	runoff.WD = 30
-	runoff.WDM = 20
-	runoff.SetParmameter(&parameter)
-
-	//流域分水源
-	var source Source.Source
-	source.N = 1
-	source.SetParmameter(&parameter)
-
-	//呈村流域汇流
-	var confluence Confluence.Confluence
-	confluence.Dt = states[0].Dt
-	confluence.SetParmameter(&parameter)
-
-	//呈村流域河道汇流
-	var muskingum Muskingum.Muskingum
-	muskingum.Dt = states[0].Dt
-	muskingum.N = 1
-	muskingum.SetParmameter(&parameter)
-
-	// 逐时段逐单元流域计算
-	nT := io.Nrows
-	io.MQ = make([]float64, nT)
-	for t := 0; t < nT; t++ {
-		states[0].Q = 0.0
-		for w := 0; w < nw; w++ {
-			states[w].SetInput(t, w, &watershed)
-			evapotranspiration.SetState(states[w])
-			evapotranspiration.Calculate()
-			evapotranspiration.UpdateState(states[w])
-			runoff.SetState(states[w])
-			runoff.Calculate()
-			runoff.UpdateState(states[w])
-			source.SetState(states[w])
-			source.Calculate()
-			source.UpdateState(states[w])
-			confluence.SetState(states[w])
-			confluence.Calculate()
-			confluence.UpdateState(states[w])
-			muskingum.SetState(states[w])
-			muskingum.Calculate()
-			muskingum.UpdateState(states[w])
-			states[0].Q += states[w].O2
-		}
-		io.MQ[t] = states[0].Q
+// readUnitParameters 读取工作目录下的单元流域参数文件，文件不存在时返回nil
+func readUnitParameters(filePath string, nw int) (Data.UnitParameters, error) {
+	if _, err := os.Stat(filePath + Data.UnitParameterFile); err != nil {
+		return nil, nil
 	}
 
-	// 输出流域出口断面流量过程到文本Q.txt中
-	io.WriteToFile(path)
+	units, err := Data.ReadUnitParameters(filePath, nw)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取单元流域参数: %w", err)
+	}
+	return units, nil
+}
+
+// readNetwork 读取工作目录下的河网文件，文件不存在时返回nil
+func readNetwork(filePath string) (*Network.Network, error) {
+	if _, err := os.Stat(filePath + Network.NetworkFile); err != nil {
+		return nil, nil
+	}
+
+	network := &Network.Network{}
+	if err := network.ReadFromFile(filePath); err != nil {
+		return nil, fmt.Errorf("无法读取河网: %w", err)
+	}
+	return network, nil
 }
 
 // PostProcessing 后处理，计算目标函数值
@@ -805,27 +1364,18 @@ func (s *SCEUA) PostProcessing() float64 {
 	// 读取模拟值
 	s.simulatedValues = s.ReadValues(s.filePath + "Q.txt")
 
-	// 计算NSE
-	nse := s.CalculateNSE(s.simulatedValues, s.measuredValues)
+	// 计算预热期之后的目标函数值
+	return s.objective.Evaluate(s.afterWarmup(s.simulatedValues), s.afterWarmup(s.measuredValues))
+}
 
-	// 返回1-NSE作为优化目标
-	return 1 - nse
+// afterWarmup 返回预热期之后的序列
+func (s *SCEUA) afterWarmup(values []float64) []float64 {
+	return values[min(s.warmup, len(values)):]
 }
 
 // CalculateNSE 计算Nash-Sutcliffe效率系数
 func (s *SCEUA) CalculateNSE(simulatedValues, measuredValues []float64) float64 {
-	var sumSquaredError, sumSquaredDeviation float64
-
-	// 计算观测流量平均值
-	measuredMean := meanSlice(measuredValues)
-
-	// 计算NSE
-	for i := range measuredValues {
-		sumSquaredError += math.Pow(measuredValues[i]-simulatedValues[i], 2)
-		sumSquaredDeviation += math.Pow(measuredValues[i]-measuredMean, 2)
-	}
-
-	return 1 - (sumSquaredError / sumSquaredDeviation)
+	return Objective.NSE(simulatedValues, measuredValues)
 }
 
 // 辅助函数
@@ -866,6 +1416,81 @@ func meanSlice(s []float64) float64 {
 	return sum / float64(len(s))
 }
 
+// SetInMemory 设置是否在内存中计算目标函数
+// 内存模式下流域、驱动及实测数据只加载一次，每组候选参数直接映射到Data.Parameter后模拟，
+// 不再读写parameter_sce.txt和Q.txt
+func (s *SCEUA) SetInMemory(inMemory bool) {
+	s.inMemory = inMemory
+}
+
+// SetWorkers 设置并行计算目标函数的协程数
+// 初始样本和各复形的演化并行计算，仅内存模式下生效，文件模式始终顺序计算
+func (s *SCEUA) SetWorkers(n int) {
+	if n < 1 {
+		n = 1
+	}
+	s.nworkers = n
+}
+
+// SetObjective 设置目标函数，优先于scein.txt中的objective设置
+func (s *SCEUA) SetObjective(objective Objective.Function) {
+	s.objective = objective
+	s.objectiveSet = true
+}
+
+// SetSeed 设置随机数种子，优先于scein.txt中的seed设置
+func (s *SCEUA) SetSeed(seed int64) {
+	s.seed = seed
+	s.seedSet = true
+}
+
+// Seed 返回本次优化使用的随机数种子
+func (s *SCEUA) Seed() int64 {
+	return s.seed
+}
+
+// SetWarmup 设置预热期时段数，预热期不参与目标函数计算，优先于scein.txt中的warmup设置
+func (s *SCEUA) SetWarmup(warmup int) {
+	s.warmup = max(warmup, 0)
+	s.warmupSet = true
+}
+
+// SetSpinup 设置预热期最大重复计算次数，优先于scein.txt中的spinup设置
+func (s *SCEUA) SetSpinup(spinup int) {
+	s.spinup = max(spinup, 0)
+	s.spinupSet = true
+}
+
+// SetFillMethod 设置降雨、蒸发缺测插补方法：zero、linear或nearest，优先于scein.txt中的fill设置
+func (s *SCEUA) SetFillMethod(method string) {
+	s.fillMethod = method
+}
+
+// SetSettings 设置率定设置，设置后不再读取scein.txt
+func (s *SCEUA) SetSettings(settings *Settings) {
+	s.applySettings(settings)
+}
+
+// Inputs 模型输入，Watershed须已由Watershed.Calculate计算出各单元流域降雨、蒸发
+type Inputs struct {
+	Watershed *Watershed.Watershed // 流域信息
+	IO        *Watershed.IO        // 驱动数据，实测流量IO.Q作为率定目标
+	Parameter *Data.Parameter      // 模型参数基准值
+	Initial   []*Data.State        // 各单元流域初始状态，可为nil
+	Network   *Network.Network     // 河网拓扑，可为nil
+	Units     Data.UnitParameters  // 各单元流域参数，可为nil
+}
+
+// SetInputs 设置模型输入，设置后不再从工作目录读取模型输入文件，目标函数只在内存中计算
+func (s *SCEUA) SetInputs(inputs *Inputs) {
+	s.inputs = inputs
+}
+
+// SetReportFile 设置率定结果输出文件，默认为工作目录下的sceout.txt
+func (s *SCEUA) SetReportFile(fileName string) {
+	s.reportFile = fileName
+}
+
 // SetFilePath 设置工作目录路径
 func (s *SCEUA) SetFilePath(path string) {
 	s.filePath = path
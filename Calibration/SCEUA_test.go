@@ -0,0 +1,304 @@
+package Calibration
+
+import (
+	"demo2/Data"
+	"demo2/Model"
+	"demo2/Network"
+	"demo2/Objective"
+	"demo2/Watershed"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// exampleInputs 读取IOexamples中的流域、驱动数据、模型参数及实测流量
+func exampleInputs(t *testing.T) *Inputs {
+	t.Helper()
+	path := "../IOexamples/"
+	in := &Inputs{Watershed: &Watershed.Watershed{}, IO: &Watershed.IO{}, Parameter: &Data.Parameter{}}
+	if err := in.Watershed.ReadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.IO.ReadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Watershed.Calculate(in.IO); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Parameter.ReadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	in.IO.Q = NewSCEUA().ReadValues(path + "observe.txt")
+	return in
+}
+
+// optimize 以给定的协程数率定，返回各次洗牌循环的最佳点及函数值
+func optimize(t *testing.T, in *Inputs, workers int) ([][]float64, []float64) {
+	t.Helper()
+	s := NewSCEUA()
+	s.SetFilePath(t.TempDir() + string(filepath.Separator))
+	s.SetInputs(in)
+	s.SetWorkers(workers)
+	s.SetSeed(7)
+	s.SetSettings(&Settings{
+		Parameters: []Range{{"KC", 0.93, 0.8, 1.0}, {"UM", 20, 10, 50}, {"KI", 0.5, 0.1, 0.6}},
+		Control: &Control{NGS: 2, NPG: 7, NPS: 4, Alpha: 1, Beta: 7, MaxN: 150, KStop: 5,
+			PCento: 0.01, PEps: 0.001, IniFlg: true},
+		Tied:        map[string]string{"KG": "0.3 * KI"},
+		Constraints: []string{"KI + KG <= 0.7"},
+	})
+	if err := s.Optimize(); err != nil {
+		t.Fatal(err)
+	}
+	return s.bestx, s.bestf
+}
+
+// 相同的随机数种子下，并行计算与顺序计算的率定过程应完全相同
+func TestOptimizeWorkersDeterministic(t *testing.T) {
+	if testing.Short() {
+		t.Skip("率定耗时较长")
+	}
+	in := exampleInputs(t)
+	bestx1, bestf1 := optimize(t, in, 1)
+	bestx4, bestf4 := optimize(t, in, 4)
+	if !reflect.DeepEqual(bestf1, bestf4) || !reflect.DeepEqual(bestx1, bestx4) {
+		t.Errorf("1个协程的最佳函数值为%v，4个协程为%v", bestf1, bestf4)
+	}
+	if bestf1[len(bestf1)-1] > bestf1[0] {
+		t.Errorf("最佳函数值由%g变大为%g", bestf1[0], bestf1[len(bestf1)-1])
+	}
+}
+
+// 预热期内的实测值不参与目标函数计算，预热期不短于计算时段时不能率定
+func TestWarmupExcludedFromObjective(t *testing.T) {
+	in := exampleInputs(t)
+	settings := &Settings{Parameters: []Range{{"KC", 0.93, 0.8, 1.0}}, Control: &Control{NGS: 2, MaxN: 10, KStop: 5, PCento: 0.01, PEps: 0.001}}
+	load := func(warmup int) (*SCEUA, error) {
+		s := NewSCEUA()
+		s.SetInputs(in)
+		s.SetSettings(settings)
+		s.SetWarmup(warmup)
+		return s, s.loadModelData()
+	}
+
+	s, err := load(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := s.evaluate(s.a)
+	measured := append([]float64(nil), s.measuredValues...)
+	for i := 0; i < 100; i++ {
+		measured[i] = 1e6
+	}
+	s.measuredValues = measured
+	if got := s.evaluate(s.a); got != f {
+		t.Errorf("改变预热期内的实测值后目标函数值由%g变为%g", f, got)
+	}
+	s.warmup = 0
+	if got := s.evaluate(s.a); got == f {
+		t.Error("没有预热期时预热期内的实测值应参与目标函数计算")
+	}
+
+	for _, warmup := range []int{in.IO.Nrows, in.IO.Nrows + 1} {
+		if _, err := load(warmup); err == nil {
+			t.Errorf("预热期%d个时段不短于计算时段%d个时应返回错误", warmup, in.IO.Nrows)
+		}
+	}
+}
+
+// copyExamples 将IOexamples复制到临时目录作为文件模式的工作目录，返回以路径分隔符结尾的目录
+func copyExamples(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	entries, err := os.ReadDir("../IOexamples")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join("../IOexamples", entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, entry.Name()), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir + string(filepath.Separator)
+}
+
+// 同一组参数在内存模式与文件模式下的流量过程及目标函数值应完全相同
+func TestInMemoryMatchesFileMode(t *testing.T) {
+	s := NewSCEUA()
+	s.SetFilePath(copyExamples(t))
+	s.SetSettings(&Settings{
+		Parameters: []Range{{"KC", 0.93, 0.8, 1.0}, {"UM", 20, 10, 50}, {"KI", 0.5, 0.1, 0.6}},
+		Control:    &Control{NGS: 2, NPG: 7, NPS: 4, Alpha: 1, Beta: 7, MaxN: 10, KStop: 5, PCento: 0.01, PEps: 0.001},
+		Tied:       map[string]string{"KG": "0.3 * KI"},
+	})
+	if err := s.scein(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, x := range [][]float64{s.a, {0.85, 35, 0.2}} {
+		s.SetInMemory(false)
+		fFile := s.functn(x)
+		qFile := s.simulatedValues
+
+		s.SetInMemory(true)
+		fMemory := s.functn(x)
+		parameter, units := s.applyParameters(x)
+		qMemory, err := s.simulate(s.watershed, parameter, units, s.initial, s.network, s.nT)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fFile != fMemory || math.IsInf(fFile, 0) {
+			t.Errorf("参数%v的目标函数值在文件模式下为%g，内存模式下为%g", x, fFile, fMemory)
+		}
+		if !reflect.DeepEqual(qFile, qMemory) {
+			t.Errorf("参数%v在文件模式与内存模式下的流量过程不一致", x)
+		}
+	}
+}
+
+// 模拟引擎Model.Run的流量过程应与由文件运行模型输出的Q.txt完全相同
+func TestModelRunMatchesRunModel(t *testing.T) {
+	path := copyExamples(t)
+	data, err := os.ReadFile(path + "parameter.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+CandidateFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := NewSCEUA()
+	s.SetFilePath(path)
+	if err := s.RunModel(); err != nil {
+		t.Fatal(err)
+	}
+	output, err := Watershed.ReadOutput(path + "Q.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := exampleInputs(t)
+	model, err := Model.NewModel(in.Watershed, in.Parameter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := model.Run(model.NumSteps())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(output.Column(0), result.Q) {
+		t.Error("Model.Run的流量过程与Q.txt不一致")
+	}
+}
+
+func TestParseParameters(t *testing.T) {
+	units := Data.UnitParameters{
+		{Group: "upper", Values: map[string]float64{"WM": 160, "KE": 24}},
+		{Values: map[string]float64{"WM": 170, "KE": 24}},
+	}
+	tests := []struct {
+		name    string
+		network bool
+		err     string // 为空时应无错误
+	}{
+		{"UM", false, ""},
+		{"UM@upper", false, ""},
+		{"UM@", false, "待率定参数UM@的分组名为空"},
+		{"UM@lower", false, "待率定参数UM@lower的分组lower在unitparameter.txt中不存在"},
+		{"FOO", false, "待率定参数FOO不是模型参数"},
+		{"WM", false, "待率定参数WM在unitparameter.txt中已为每个单元流域给出"},
+		{"KE", false, "待率定参数KE在unitparameter.txt中已为每个单元流域给出"},
+		{"KE", true, ""},
+	}
+	for _, tt := range tests {
+		s := NewSCEUA()
+		s.SetInMemory(true)
+		s.SetSettings(&Settings{Parameters: []Range{{tt.name, 1, 0, 2}}})
+		s.parameter = Data.NewParameter(0.9, 20, 70, 0.15, 150, 0.3, 0.01, 30, 1.2, 0.3, 0.4, 0.2, 0.7, 0.98, 0.2, 24, 0.3)
+		s.units = units
+		if tt.network {
+			s.network = &Network.Network{}
+		}
+		err := s.parseParameters()
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.err)) {
+			t.Errorf("%s（河网%v）的错误为%v，应为%q", tt.name, tt.network, err, tt.err)
+		}
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	s := NewSCEUA()
+	s.SetSeed(7)
+	s.applySettings(&Settings{
+		Parameters: []Range{{"KC", 0.93, 0.8, 1.0}, {"UM@upper", 20, 10, 50}},
+		Control: &Control{NGS: 2, NPG: 5, NPS: 3, Alpha: 1, Beta: 5, MaxN: 100, KStop: 5,
+			PCento: 0.01, PEps: 0.001, IniFlg: true},
+	})
+	s.fa = 0.4
+	s.icall = []int{10, 25}
+	s.timeou = []float64{0.5, 0.005}
+	s.gnrng = []float64{0.2, 0.1}
+	s.bestf = []float64{0.3, 0.25, 0.2}
+	s.bestx = [][]float64{{0.9, 30}, {0.95, 25}, {0.96, 24.5}}
+	s.stopReason = "pcento"
+
+	var b strings.Builder
+	s.writeReport(&b)
+	want := `[result]
+objective 1-NSE
+seed 7
+stop pcento
+nloop 2
+icall 25
+initial_f 0.4
+best_f 0.2
+infeasible 0
+
+[control]
+ngs 2
+npg 5
+nps 3
+alpha 1
+beta 5
+npt 10
+maxn 100
+kstop 5
+pcento 0.01
+peps 0.001
+iniflg true
+warmup 0
+spinup 0
+
+[parameters]
+name initial lower upper best
+KC 0.93 0.8 1 0.96
+UM@upper 20 10 50 24.5
+
+[history]
+loop icall timeou gnrng bestf
+1 10 0.5 0.2 0.25
+2 25 0.005 0.1 0.2
+
+`
+	if got := b.String(); got != want {
+		t.Errorf("率定结果为\n%s\n应为\n%s", got, want)
+	}
+
+	// 加权组合目标函数输出各项的含义
+	objective, err := Objective.ByName("NSE:0.7,Volume:0.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetObjective(objective)
+	b.Reset()
+	s.writeReport(&b)
+	if line := "objective 0.7*(1-NSE)+0.3*|Volume|\n"; !strings.Contains(b.String(), line) {
+		t.Errorf("率定结果中缺少%q", line)
+	}
+}
@@ -0,0 +1,168 @@
+package Calibration
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Range 待率定参数的初始值及上下限
+type Range struct {
+	Name    string  `json:"name"`    // 参数名，可为“参数名@分组”
+	Initial float64 `json:"initial"` // 初始值
+	Lower   float64 `json:"lower"`   // 下限
+	Upper   float64 `json:"upper"`   // 上限
+}
+
+// Control SCE-UA控制参数
+type Control struct {
+	NGS    int     `json:"ngs"`    // 复形数量
+	NPG    int     `json:"npg"`    // 每个复形中的点的个数
+	NPS    int     `json:"nps"`    // 子复形中的点数
+	Alpha  int     `json:"alpha"`  // CCE步骤中重复次数
+	Beta   int     `json:"beta"`   // CCE步骤3-5重复次数
+	MaxN   int     `json:"maxn"`   // 最大试验次数
+	KStop  int     `json:"kstop"`  // 收敛判断循环数
+	PCento float64 `json:"pcento"` // 函数值变化百分比
+	PEps   float64 `json:"peps"`   // 最低变化率
+	IniFlg bool    `json:"iniflg"` // 是否包含初始点
+	IPrint bool    `json:"iprint"` // 打印控制标志
+}
+
+// Settings 参数率定设置，可由scein.txt读取或由项目配置给出
+type Settings struct {
+	Parameters  []Range            `json:"parameters"`            // 待率定参数
+	Control     *Control           `json:"control,omitempty"`     // SCE-UA控制参数，为nil时使用默认值
+	Objective   string             `json:"objective,omitempty"`   // 目标函数，如NSE、“NSE:0.7,Volume:0.3”，为空时为1-NSE
+	Seed        *int64             `json:"seed,omitempty"`        // 随机数种子，为nil时取当前时间，可显式给出0
+	Fill        string             `json:"fill,omitempty"`        // 降雨、蒸发缺测插补方法
+	Warmup      int                `json:"warmup,omitempty"`      // 预热期时段数
+	Spinup      int                `json:"spinup,omitempty"`      // 预热期最大重复计算次数
+	Fixed       map[string]float64 `json:"fixed,omitempty"`       // 固定参数，取代模型参数中的值
+	Tied        map[string]string  `json:"tied,omitempty"`        // 关联参数名到表达式，如"KG": "0.3 * KI"
+	Constraints []string           `json:"constraints,omitempty"` // 参数间约束，如“KI + KG <= 0.7”
+}
+
+// ReadSettings 读取scein.txt格式的率定设置：
+//
+//	参数个数
+//	参数名 初始值 下限 上限          每个待率定参数一行
+//	是否给出控制参数(true/false)
+//	ngs npg nps alpha beta          以下三行仅在给出控制参数时存在
+//	maxn kstop pcento peps
+//	iniflg iprint
+//	键 值                           可选设置，每行一个，见下
+//
+// 可选设置有seed、objective、fill、warmup、spinup，以及固定参数、关联参数及参数间约束：
+//
+//	fixed CS 0.2               // 固定参数，取代parameter.txt中的值
+//	tie KG = 0.3 * KI          // 关联参数，由待率定参数按表达式计算
+//	constraint KI + KG <= 0.7  // 参数间约束
+func ReadSettings(fileName string) (*Settings, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("无法打开输入文件: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	settings := &Settings{}
+
+	// 读取参数个数
+	nopt := 0
+	if scanner.Scan() {
+		fmt.Sscanf(scanner.Text(), "%d", &nopt)
+	}
+
+	// 读取每个变量的参数名、初始值、下限、上限
+	settings.Parameters = make([]Range, nopt)
+	for i := range settings.Parameters {
+		if scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) >= 4 {
+				r := &settings.Parameters[i]
+				r.Name = fields[0]
+				fmt.Sscanf(fields[1], "%f", &r.Initial)
+				fmt.Sscanf(fields[2], "%f", &r.Lower)
+				fmt.Sscanf(fields[3], "%f", &r.Upper)
+			}
+		}
+	}
+
+	// 读取是否给出控制参数
+	ideflt := false
+	if scanner.Scan() {
+		fmt.Sscanf(scanner.Text(), "%t", &ideflt)
+	}
+	if ideflt {
+		c := &Control{}
+		if scanner.Scan() {
+			fmt.Sscanf(scanner.Text(), "%d %d %d %d %d", &c.NGS, &c.NPG, &c.NPS, &c.Alpha, &c.Beta)
+		}
+		if scanner.Scan() {
+			fmt.Sscanf(scanner.Text(), "%d %d %f %f", &c.MaxN, &c.KStop, &c.PCento, &c.PEps)
+		}
+		if scanner.Scan() {
+			fmt.Sscanf(scanner.Text(), "%t %t", &c.IniFlg, &c.IPrint)
+		}
+		settings.Control = c
+	}
+
+	// 读取可选的键值设置行
+	line := 2 + nopt
+	if ideflt {
+		line += 3
+	}
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch fields[0] {
+		case "seed":
+			seed, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s第%d行: 随机数种子无法解析: %w", fileName, line, err)
+			}
+			settings.Seed = &seed
+		case "objective":
+			settings.Objective = strings.Join(fields[1:], "")
+		case "fill":
+			settings.Fill = fields[1]
+		case "warmup":
+			fmt.Sscanf(fields[1], "%d", &settings.Warmup)
+		case "spinup":
+			fmt.Sscanf(fields[1], "%d", &settings.Spinup)
+		case "fixed":
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("%s第%d行: 固定参数应为“fixed 参数名 值”", fileName, line)
+			}
+			value, err := strconv.ParseFloat(fields[2], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s第%d行: 固定参数%s的值无法解析: %w", fileName, line, fields[1], err)
+			}
+			if settings.Fixed == nil {
+				settings.Fixed = make(map[string]float64)
+			}
+			settings.Fixed[fields[1]] = value
+		case "tie":
+			name, expr, ok := strings.Cut(strings.Join(fields[1:], " "), "=")
+			if !ok {
+				return nil, fmt.Errorf("%s第%d行: 关联参数应为“tie 参数名 = 表达式”", fileName, line)
+			}
+			if settings.Tied == nil {
+				settings.Tied = make(map[string]string)
+			}
+			settings.Tied[strings.TrimSpace(name)] = strings.TrimSpace(expr)
+		case "constraint":
+			settings.Constraints = append(settings.Constraints, strings.Join(fields[1:], " "))
+		default:
+			fmt.Printf("未知的设置项: %s\n", fields[0])
+		}
+	}
+	return settings, nil
+}
@@ -0,0 +1,117 @@
+package Calibration
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFile 在临时目录中写入测试文件，返回文件路径
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	fileName := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(fileName, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return fileName
+}
+
+func TestReadSettings(t *testing.T) {
+	fileName := writeFile(t, "scein.txt", `2
+KC 0.93 0.8 1.0
+KI 0.5 0.1 0.9
+true
+5 15 8 1 15
+600 5 0.1 0.001
+true false
+seed 7
+objective NSE: 0.7, Volume: 0.3
+warmup 30
+fixed CS 0.25
+tie KG = 0.3 * KI
+constraint KI + KG <= 0.7
+`)
+	settings, err := ReadSettings(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed := int64(7)
+	want := &Settings{
+		Parameters: []Range{{"KC", 0.93, 0.8, 1.0}, {"KI", 0.5, 0.1, 0.9}},
+		Control: &Control{NGS: 5, NPG: 15, NPS: 8, Alpha: 1, Beta: 15, MaxN: 600, KStop: 5,
+			PCento: 0.1, PEps: 0.001, IniFlg: true},
+		Objective:   "NSE:0.7,Volume:0.3",
+		Seed:        &seed,
+		Warmup:      30,
+		Fixed:       map[string]float64{"CS": 0.25},
+		Tied:        map[string]string{"KG": "0.3 * KI"},
+		Constraints: []string{"KI + KG <= 0.7"},
+	}
+	if !reflect.DeepEqual(settings, want) {
+		t.Errorf("得到%+v，应为%+v", settings, want)
+	}
+}
+
+func TestReadSettingsDefaultControl(t *testing.T) {
+	settings, err := ReadSettings(writeFile(t, "scein.txt", "1\nKC 0.93 0.8 1.0\nfalse\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Control != nil || len(settings.Parameters) != 1 {
+		t.Errorf("得到%+v", settings)
+	}
+}
+
+func TestReadSettingsErrors(t *testing.T) {
+	tests := []struct {
+		line string
+		err  string
+	}{
+		{"fixed CS", "第4行: 固定参数应为“fixed 参数名 值”"},
+		{"fixed CS abc", "第4行: 固定参数CS的值无法解析"},
+		{"tie KG 0.3 * KI", "第4行: 关联参数应为“tie 参数名 = 表达式”"},
+		{"seed abc", "第4行: 随机数种子无法解析"},
+	}
+	for _, tt := range tests {
+		_, err := ReadSettings(writeFile(t, "scein.txt", "1\nKC 0.93 0.8 1.0\nfalse\n"+tt.line+"\n"))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: 错误为%v，应包含“%s”", tt.line, err, tt.err)
+		}
+	}
+	if _, err := ReadSettings(filepath.Join(t.TempDir(), "scein.txt")); err == nil {
+		t.Error("文件不存在时应返回错误")
+	}
+}
+
+// 显式给出的种子0应被采用，未给出种子时使用当前时间，SetSeed指定的种子优先
+func TestSeedZero(t *testing.T) {
+	settings, err := ReadSettings(writeFile(t, "scein.txt", "1\nKC 0.93 0.8 1.0\nfalse\nseed 0\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Seed == nil || *settings.Seed != 0 {
+		t.Fatalf("随机数种子为%v，应为0", settings.Seed)
+	}
+	s := NewSCEUA()
+	s.applySettings(settings)
+	if s.Seed() != 0 {
+		t.Errorf("显式给出种子0时使用的种子为%d", s.Seed())
+	}
+
+	s = NewSCEUA()
+	s.SetSeed(5)
+	s.applySettings(settings)
+	if s.Seed() != 5 {
+		t.Errorf("SetSeed指定5时使用的种子为%d", s.Seed())
+	}
+
+	s = NewSCEUA()
+	seed := s.Seed()
+	settings.Seed = nil
+	s.applySettings(settings)
+	if s.Seed() != seed {
+		t.Errorf("未给出种子时种子由%d变为%d", seed, s.Seed())
+	}
+}
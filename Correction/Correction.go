@@ -0,0 +1,255 @@
+package Correction
+
+import (
+	"demo2/Data"
+	"demo2/Objective"
+	"fmt"
+	"math"
+)
+
+// 实时校正方法
+const (
+	None    = ""        // 不校正
+	AR      = "ar"      // 对发布时刻之前的模拟误差建立AR(n)模型，外推预见期误差叠加到模拟流量上
+	Routing = "routing" // 按发布时刻实测与模拟流量之比调整各单元流域QS、QI、QG及河道汇流状态
+	Storage = "storage" // 按发布时刻实测与模拟流量之比调整各单元流域自由水蓄量S
+)
+
+// 状态修正系数的取值范围，防止个别异常实测值使状态失真
+const (
+	MinRatio = 0.1
+	MaxRatio = 10.0
+)
+
+// Options 实时校正设置
+type Options struct {
+	Method string // 校正方法：ar、routing或storage
+	Order  int    // AR模型阶数n
+	Window int    // 计算状态修正系数时使用的发布时刻之前的时段数
+}
+
+// Check 检查校正设置
+func (o *Options) Check() error {
+	switch o.Method {
+	case None, Routing, Storage:
+	case AR:
+		if o.Order < 1 {
+			return fmt.Errorf("AR模型阶数%d应不小于1", o.Order)
+		}
+	default:
+		return fmt.Errorf("未知的校正方法%s，可选ar、routing或storage", o.Method)
+	}
+	if o.Method != AR && o.Method != None && o.Window < 1 {
+		return fmt.Errorf("计算修正系数的时段数%d应不小于1", o.Window)
+	}
+	return nil
+}
+
+// Skill 流量过程的评价指标
+type Skill struct {
+	N     int     // 参与评价的时段数
+	NSE   float64 // 纳什效率系数
+	RMSE  float64 // 均方根误差，m3/s
+	PBIAS float64 // 相对偏差，%
+}
+
+// Evaluate 计算模拟流量相对实测流量的评价指标，实测缺测的时段不参与评价
+func Evaluate(simulated, measured []float64) Skill {
+	sim, obs := Objective.Paired(simulated, measured)
+	if len(sim) == 0 {
+		return Skill{}
+	}
+	return Skill{
+		N:     len(sim),
+		NSE:   Objective.NSE(sim, obs),
+		RMSE:  Objective.RMSE(sim, obs),
+		PBIAS: Objective.PBIAS(sim, obs),
+	}
+}
+
+// Comparison 校正前后的评价指标
+type Comparison struct {
+	Period string // 评价时段说明
+	Before Skill  // 校正前
+	After  Skill  // 校正后
+}
+
+// Residuals 返回实测减模拟的误差序列，实测缺测时为NaN
+func Residuals(simulated, measured []float64) []float64 {
+	e := make([]float64, len(simulated))
+	for t := range e {
+		e[t] = measured[t] - simulated[t]
+	}
+	return e
+}
+
+// FitAR 以最小二乘法拟合误差的AR(n)模型 e(t) = a1*e(t-1) + ... + an*e(t-n)，返回系数a1...an
+// 含缺测的样本不参与拟合
+func FitAR(residuals []float64, order int) ([]float64, error) {
+	// 正规方程 (XᵀX)a = Xᵀy
+	xtx := make([][]float64, order)
+	for i := range xtx {
+		xtx[i] = make([]float64, order)
+	}
+	xty := make([]float64, order)
+	samples := 0
+	for t := order; t < len(residuals); t++ {
+		y := residuals[t]
+		x := residuals[t-order : t]
+		if math.IsNaN(y) || hasNaN(x) {
+			continue
+		}
+		samples++
+		for i := 0; i < order; i++ {
+			xi := residuals[t-1-i]
+			xty[i] += xi * y
+			for j := 0; j < order; j++ {
+				xtx[i][j] += xi * residuals[t-1-j]
+			}
+		}
+	}
+	if samples <= order {
+		return nil, fmt.Errorf("有效误差样本%d个，不足以拟合%d阶AR模型", samples, order)
+	}
+
+	coef, err := solve(xtx, xty)
+	if err != nil {
+		return nil, fmt.Errorf("AR模型拟合失败: %w", err)
+	}
+	return coef, nil
+}
+
+// PredictAR 由最近的误差history外推后lead个时段的误差，history末项为最近一个时段
+func PredictAR(coef, history []float64, lead int) []float64 {
+	order := len(coef)
+	e := append([]float64(nil), history...)
+	for len(e) < order {
+		e = append([]float64{0}, e...)
+	}
+	for i, v := range e {
+		if math.IsNaN(v) {
+			e[i] = 0
+		}
+	}
+
+	predicted := make([]float64, lead)
+	for k := 0; k < lead; k++ {
+		next := 0.0
+		for i, a := range coef {
+			next += a * e[len(e)-1-i]
+		}
+		predicted[k] = next
+		e = append(e, next)
+	}
+	return predicted
+}
+
+// OneStepAR 返回AR模型对误差序列的一步预报，前n个时段及历史含缺测的时段为0
+func OneStepAR(coef, residuals []float64) []float64 {
+	order := len(coef)
+	predicted := make([]float64, len(residuals))
+	for t := order; t < len(residuals); t++ {
+		x := residuals[t-order : t]
+		if hasNaN(x) {
+			continue
+		}
+		for i, a := range coef {
+			predicted[t] += a * residuals[t-1-i]
+		}
+	}
+	return predicted
+}
+
+// Ratio 返回最近window个时段实测与模拟流量均值之比，限制在[MinRatio, MaxRatio]内
+// 没有有效实测或模拟流量为0时返回1，即不修正
+func Ratio(simulated, measured []float64, window int) float64 {
+	from := max(len(simulated)-window, 0)
+	sim, obs := Objective.Paired(simulated[from:], measured[from:])
+	sumSim, sumObs := 0.0, 0.0
+	for i := range sim {
+		sumSim += sim[i]
+		sumObs += obs[i]
+	}
+	if len(sim) == 0 || sumSim <= 0 {
+		return 1
+	}
+	return math.Min(math.Max(sumObs/sumSim, MinRatio), MaxRatio)
+}
+
+// UpdateRouting 将各单元流域的QS、QI、QG、QU及河道汇流状态按比例ratio修正
+func UpdateRouting(states, reaches []*Data.State, ratio float64) {
+	for _, state := range states {
+		state.QS *= ratio
+		state.QI *= ratio
+		state.QG *= ratio
+		state.QU *= ratio
+		scaleRouting(state, ratio)
+	}
+	for _, reach := range reaches {
+		reach.QU *= ratio
+		scaleRouting(reach, ratio)
+	}
+}
+
+// UpdateStorage 将各单元流域的自由水蓄量S按比例ratio修正，并限制在自由水蓄水容量SM内
+func UpdateStorage(states []*Data.State, parameters []*Data.Parameter, ratio float64) {
+	for w, state := range states {
+		state.S0 = math.Min(state.S0*ratio, parameters[w].SM)
+	}
+}
+
+// scaleRouting 将河道汇流各子河段出流按比例修正
+func scaleRouting(state *Data.State, ratio float64) {
+	for i := range state.O {
+		state.O[i] *= ratio
+	}
+	state.O2 *= ratio
+}
+
+// hasNaN 判断序列中是否有缺测
+func hasNaN(values []float64) bool {
+	for _, v := range values {
+		if math.IsNaN(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// solve 以列主元高斯消去法求解线性方程组
+func solve(a [][]float64, b []float64) ([]float64, error) {
+	n := len(b)
+	m := make([][]float64, n)
+	for i := range m {
+		m[i] = append(append([]float64(nil), a[i]...), b[i])
+	}
+
+	for k := 0; k < n; k++ {
+		pivot := k
+		for i := k + 1; i < n; i++ {
+			if math.Abs(m[i][k]) > math.Abs(m[pivot][k]) {
+				pivot = i
+			}
+		}
+		if math.Abs(m[pivot][k]) < 1e-12 {
+			return nil, fmt.Errorf("方程组奇异")
+		}
+		m[k], m[pivot] = m[pivot], m[k]
+		for i := k + 1; i < n; i++ {
+			f := m[i][k] / m[k][k]
+			for j := k; j <= n; j++ {
+				m[i][j] -= f * m[k][j]
+			}
+		}
+	}
+
+	x := make([]float64, n)
+	for i := n - 1; i >= 0; i-- {
+		x[i] = m[i][n]
+		for j := i + 1; j < n; j++ {
+			x[i] -= m[i][j] * x[j]
+		}
+		x[i] /= m[i][i]
+	}
+	return x, nil
+}
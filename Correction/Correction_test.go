@@ -0,0 +1,132 @@
+package Correction
+
+import (
+	"demo2/Data"
+	"math"
+	"testing"
+)
+
+func TestSolve(t *testing.T) {
+	// 首行主元为0，须交换行
+	a := [][]float64{{0, 2, 1}, {1, 1, 1}, {2, 1, 3}}
+	b := []float64{7, 6, 13}
+	x, err := solve(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []float64{1, 2, 3} {
+		if math.Abs(x[i]-want) > 1e-12 {
+			t.Errorf("解为%v，应为[1 2 3]", x)
+			break
+		}
+	}
+	if a[0][0] != 0 || b[0] != 7 {
+		t.Error("solve不应修改系数矩阵")
+	}
+
+	if _, err := solve([][]float64{{1, 2}, {2, 4}}, []float64{1, 2}); err == nil {
+		t.Error("奇异方程组应返回错误")
+	}
+}
+
+// 由已知系数生成的AR(2)误差序列应能拟合出原系数，缺测样本不参与拟合
+func TestFitAR(t *testing.T) {
+	e := []float64{1, -0.5}
+	for i := len(e); i < 60; i++ {
+		e = append(e, 0.6*e[i-1]-0.2*e[i-2])
+	}
+	e[30] = math.NaN()
+	coef, err := FitAR(e, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(coef[0]-0.6) > 1e-9 || math.Abs(coef[1]+0.2) > 1e-9 {
+		t.Errorf("AR系数为%v，应为[0.6 -0.2]", coef)
+	}
+
+	nan := math.NaN()
+	for _, residuals := range [][]float64{{1, 2, 3}, {1, nan, 2, nan, 3, nan}, {0, 0, 0, 0, 0}} {
+		if _, err := FitAR(residuals, 2); err == nil {
+			t.Errorf("%v应无法拟合2阶AR模型", residuals)
+		}
+	}
+}
+
+func TestPredictAR(t *testing.T) {
+	coef := []float64{0.5, 0.25}
+	got := PredictAR(coef, []float64{4, 8}, 3)
+	want := []float64{5, 4.5, 3.5}
+	for k := range want {
+		if got[k] != want[k] {
+			t.Fatalf("外推误差为%v，应为%v", got, want)
+		}
+	}
+	// 历史不足n个时段或缺测时按0计
+	if got := PredictAR(coef, []float64{math.NaN(), 8}, 1); got[0] != 4 {
+		t.Errorf("外推误差为%v，应为[4]", got)
+	}
+	if got := PredictAR(coef, []float64{8}, 1); got[0] != 4 {
+		t.Errorf("外推误差为%v，应为[4]", got)
+	}
+}
+
+func TestOneStepAR(t *testing.T) {
+	nan := math.NaN()
+	got := OneStepAR([]float64{0.5}, []float64{2, 4, nan, 6})
+	want := []float64{0, 1, 2, 0}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("一步预报为%v，应为%v", got, want)
+		}
+	}
+}
+
+func TestRatio(t *testing.T) {
+	nan := math.NaN()
+	tests := []struct {
+		name      string
+		simulated []float64
+		measured  []float64
+		want      float64
+	}{
+		{"窗口内均值之比", []float64{100, 10, 20}, []float64{1, 15, 30}, 1.5},
+		{"缺测不参与", []float64{10, 20}, []float64{nan, 30}, 1.5},
+		{"全部缺测", []float64{10, 20}, []float64{nan, nan}, 1},
+		{"模拟为0", []float64{0, 0}, []float64{1, 2}, 1},
+		{"限制上限", []float64{1, 1}, []float64{50, 50}, MaxRatio},
+		{"限制下限", []float64{50, 50}, []float64{1, 1}, MinRatio},
+	}
+	for _, tt := range tests {
+		if got := Ratio(tt.simulated, tt.measured, 2); got != tt.want {
+			t.Errorf("%s: 修正系数为%g，应为%g", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUpdateStorage(t *testing.T) {
+	states := []*Data.State{{S0: 10}, {S0: 25}}
+	parameters := []*Data.Parameter{{SM: 30}, {SM: 30}}
+	UpdateStorage(states, parameters, 2)
+	if states[0].S0 != 20 || states[1].S0 != 30 {
+		t.Errorf("修正后的自由水蓄量为%g、%g，应为20、30", states[0].S0, states[1].S0)
+	}
+}
+
+func TestOptionsCheck(t *testing.T) {
+	tests := []struct {
+		options Options
+		wantErr bool
+	}{
+		{Options{}, false},
+		{Options{Method: AR, Order: 2}, false},
+		{Options{Method: AR}, true},
+		{Options{Method: Routing, Window: 3}, false},
+		{Options{Method: Storage}, true},
+		{Options{Method: "kalman"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.options.Check(); (err != nil) != tt.wantErr {
+			t.Errorf("%+v的检查结果为%v", tt.options, err)
+		}
+	}
+}
@@ -3,7 +3,9 @@ package Data
 import (
 	"bufio"
 	"demo2/Watershed"
+	"errors"
 	"fmt"
+	"math"
 	"os"
 	"strconv"
 	"strings"
@@ -66,16 +68,158 @@ func (s *State) SetInput(nt, nw int, watershed *Watershed.Watershed) {
 	s.F = watershed.GetF(nw)
 }
 
-// 从文件读取时段长
-func (s *State) ReadFromFile(filePath string) {
-	//file, err := os.Open(filePath + "time.txt")
-	//if err != nil {
-	//	fmt.Printf("无法打开文件: %s, 错误: %v\n", filePath, err)
-	//	return
-	//}
-	//defer file.Close()
-	// 模拟读取逻辑
-	s.Dt = 24.0 // 假定读取的值
+// 从time.txt读取时段长，文件不存在或未给出时段长时取24h
+func (s *State) ReadFromFile(filePath string) error {
+	_, step, err := Watershed.ReadTimeFile(filePath + "time.txt")
+	if err != nil {
+		return err
+	}
+	s.Dt = 24.0
+	if step > 0 {
+		s.Dt = step.Hours()
+	}
+	return CheckDt(s.Dt)
+}
+
+// 检查模型计算时段长，须能整除24h，如1h、3h、6h、12h、24h
+func CheckDt(dt float64) error {
+	if dt <= 0 || dt > 24 {
+		return fmt.Errorf("时段长%gh应在(0, 24]内", dt)
+	}
+	m := 24 / dt
+	if math.Abs(m-math.Round(m)) > 1e-6 {
+		return fmt.Errorf("时段长%gh不能整除24h", dt)
+	}
+	return nil
+}
+
+// 计算马斯京根法的子河段数N = KE/∆t，KE须为∆t的正整数倍
+func ReachCount(KE, dt float64) (int, error) {
+	n := KE / dt
+	if math.Round(n) < 1 || math.Abs(n-math.Round(n)) > 1e-6 {
+		return 0, fmt.Errorf("KE=%gh不是时段长%gh的正整数倍，无法划分子河段", KE, dt)
+	}
+	return int(math.Round(n)), nil
+}
+
+// 初始状态文件名，每个单元流域一行：编号 WU WL WD S0 FR QS QI QG [O...]
+const InitialStateFile = "initstate.txt"
+
+// 从initstate.txt读取各单元流域的初始状态，nw为单元流域个数
+// 文件首行为单元流域个数，其后每行依次为单元流域编号（从1开始）、
+// 上、下、深层张力水蓄量WU、WL、WD（mm），自由水深S0（mm），产流面积比例FR，
+// 地面径流、壤中流、地下径流汇流QS、QI、QG（m3/s），以及可选的各子河段出流O（m3/s），
+// 每行“//”之后为注释
+func ReadInitialStates(filePath string, nw int) ([]*State, error) {
+	return ReadInitialStateFile(filePath+InitialStateFile, nw)
+}
+
+// 从指定的初始状态文件读取各单元流域的初始状态，格式同initstate.txt
+func ReadInitialStateFile(fileName string, nw int) ([]*State, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("无法打开初始状态文件: %v", err)
+	}
+	defer file.Close()
+
+	var rows [][]float64
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if i := strings.Index(text, "//"); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		row := make([]float64, len(fields))
+		for i, field := range fields {
+			row[i], err = strconv.ParseFloat(field, 64)
+			if err != nil {
+				return nil, &Watershed.ParseError{File: fileName, Line: line, Column: i + 1, Err: err}
+			}
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 || int(rows[0][0]) != nw || len(rows)-1 != nw {
+		return nil, fmt.Errorf("%s中的单元流域数与流域信息不一致，应为%d个", fileName, nw)
+	}
+
+	states := make([]*State, nw)
+	for _, row := range rows[1:] {
+		if len(row) < 9 {
+			return nil, fmt.Errorf("%s中每个单元流域至少需要9列，实际为%d列", fileName, len(row))
+		}
+		w := int(row[0]) - 1
+		if w < 0 || w >= nw || states[w] != nil {
+			return nil, fmt.Errorf("%s中的单元流域编号%d无效或重复", fileName, int(row[0]))
+		}
+		state := &State{
+			WU: row[1],
+			WL: row[2],
+			WD: row[3],
+			S0: row[4],
+			FR: row[5],
+			QS: row[6],
+			QI: row[7],
+			QG: row[8],
+		}
+		state.W = state.WU + state.WL + state.WD
+		state.QU = state.QS + state.QI + state.QG // 初始时刻单元流域出口流量取三种水源汇流之和
+		if len(row) > 9 {
+			state.O = append([]float64(nil), row[9:]...)
+		}
+		states[w] = state
+	}
+
+	return states, nil
+}
+
+// 检查初始状态是否与模型参数相容，返回所有不相容项
+func (s *State) CheckInitial(p *Parameter) error {
+	var errs []error
+	check := func(name string, value, lower, upper float64) {
+		if value < lower || value > upper {
+			errs = append(errs, fmt.Errorf("%s=%g超出范围[%g, %g]", name, value, lower, upper))
+		}
+	}
+
+	check("WU", s.WU, 0, p.UM)
+	check("WL", s.WL, 0, p.LM)
+	check("WD", s.WD, 0, p.WM-p.UM-p.LM)
+	check("S0", s.S0, 0, p.SM)
+	check("FR", s.FR, 0, 1)
+	check("QS", s.QS, 0, math.Inf(1))
+	check("QI", s.QI, 0, math.Inf(1))
+	check("QG", s.QG, 0, math.Inf(1))
+	for i, o := range s.O {
+		check(fmt.Sprintf("O[%d]", i), o, 0, math.Inf(1))
+	}
+	if s.O != nil && s.Dt > 0 {
+		if n, err := ReachCount(p.KE, s.Dt); err != nil {
+			errs = append(errs, err)
+		} else if len(s.O) != n {
+			errs = append(errs, fmt.Errorf("子河段出流个数%d与河段数KE/Dt=%d不一致", len(s.O), n))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// 将初始张力水蓄量限制在参数给定的容量内，用于参数率定中随参数变化的容量
+func (s *State) ClampTo(p *Parameter) {
+	s.WU = math.Min(math.Max(s.WU, 0), p.UM)
+	s.WL = math.Min(math.Max(s.WL, 0), p.LM)
+	s.WD = math.Min(math.Max(s.WD, 0), math.Max(p.WM-p.UM-p.LM, 0))
+	s.W = s.WU + s.WL + s.WD
+	s.S0 = math.Min(math.Max(s.S0, 0), p.SM)
 }
 
 // 设置状态值
@@ -155,54 +299,138 @@ func NewParameter(KC, UM, LM, C, WM, B, IM, SM, EX, KG, KI, CS, CI, CG, CR, KE,
 }
 
 // 从文件中读取模型参数
-func (p *Parameter) ReadFromFile(filePath string) {
-	file, err := os.Open(filePath + "parameter.txt")
+func (p *Parameter) ReadFromFile(filePath string) error {
+	return p.ReadFile(filePath + "parameter.txt")
+}
+
+// 参数文件中的参数个数
+const NumParameters = 17
+
+// 从指定的参数文件中读取模型参数，依次为KC、UM、LM、C、WM、B、IM、SM、EX、KG、KI、CS、CI、CG、CR、KE、XE
+// 每行“//”之后为注释，空行忽略；解析失败或参数个数不为17时返回错误且不修改参数
+func (p *Parameter) ReadFile(fileName string) error {
+	file, err := os.Open(fileName)
 	if err != nil {
-		fmt.Printf("无法打开文件: %s, 错误: %v\n", filePath, err)
-		return
+		return err
 	}
 	defer file.Close()
 
 	scanner := bufio.NewScanner(file)
 
 	var values []float64
+	line := 0
 	for scanner.Scan() {
-		line := scanner.Text()
-		fields := strings.Fields(line)
-		for _, field := range fields {
+		line++
+		text := scanner.Text()
+		if i := strings.Index(text, "//"); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		for i, field := range fields {
 			value, err := strconv.ParseFloat(field, 64)
 			if err != nil {
-				fmt.Printf("解析失败: %s, 错误: %v\n", field, err)
-				continue
+				return &Watershed.ParseError{File: fileName, Line: line, Column: i + 1, Err: err}
 			}
 			values = append(values, value)
 		}
 	}
+	if err := scanner.Err(); err != nil {
+		return &Watershed.ParseError{File: fileName, Err: err}
+	}
 
-	// 验证读取的数据是否足够
-	if len(values) < 17 {
-		fmt.Printf("参数文件数据不足，读取的数量: %d\n", len(values))
-		return
+	// 验证读取的数据个数
+	if len(values) != NumParameters {
+		return &Watershed.ParseError{File: fileName, Err: fmt.Errorf("应有%d个参数，实际读取%d个", NumParameters, len(values))}
 	}
 
 	// 分别设置参数
-	p.KC = values[0]
-	p.UM = values[1]
-	p.LM = values[2]
-	p.C = values[3]
-	p.WM = values[4]
-	p.B = values[5]
-	p.IM = values[6]
-	p.SM = values[7]
-	p.EX = values[8]
-	p.KG = values[9]
-	p.KI = values[10]
-	p.CS = values[11]
-	p.CI = values[12]
-	p.CG = values[13]
-	p.CR = values[14]
-	p.KE = values[15]
-	p.XE = values[16]
+	for i, name := range parameterNames {
+		p.Set(name, values[i])
+	}
+
+	return nil
+}
+
+// 参数名，按参数文件中的顺序排列
+var parameterNames = []string{"KC", "UM", "LM", "C", "WM", "B", "IM", "SM", "EX", "KG", "KI", "CS", "CI", "CG", "CR", "KE", "XE"}
+
+// ParameterNames 返回全部模型参数名，按参数文件中的顺序排列
+func ParameterNames() []string {
+	return append([]string(nil), parameterNames...)
+}
+
+// IsParameter 返回name是否为模型参数名
+func IsParameter(name string) bool {
+	var p Parameter
+	return p.field(name) != nil
+}
+
+// field 返回参数名对应的字段，参数名不存在时返回nil
+func (p *Parameter) field(name string) *float64 {
+	switch name {
+	case "KC":
+		return &p.KC
+	case "UM":
+		return &p.UM
+	case "LM":
+		return &p.LM
+	case "C":
+		return &p.C
+	case "WM":
+		return &p.WM
+	case "B":
+		return &p.B
+	case "IM":
+		return &p.IM
+	case "SM":
+		return &p.SM
+	case "EX":
+		return &p.EX
+	case "KG":
+		return &p.KG
+	case "KI":
+		return &p.KI
+	case "CS":
+		return &p.CS
+	case "CI":
+		return &p.CI
+	case "CG":
+		return &p.CG
+	case "CR":
+		return &p.CR
+	case "KE":
+		return &p.KE
+	case "XE":
+		return &p.XE
+	}
+	return nil
+}
+
+// 按参数名读取模型参数值，参数名不存在时返回false
+func (p *Parameter) Get(name string) (float64, bool) {
+	if f := p.field(name); f != nil {
+		return *f, true
+	}
+	return 0, false
+}
+
+// 按参数名设置模型参数值，参数名不存在时返回false
+func (p *Parameter) Set(name string, value float64) bool {
+	if f := p.field(name); f != nil {
+		*f = value
+		return true
+	}
+	return false
+}
+
+// 输出模型参数文件，每行一个参数，“//”之后注明参数名，可由ReadFile读取
+func (p *Parameter) WriteFile(fileName string) error {
+	var b strings.Builder
+	for _, name := range parameterNames {
+		value, _ := p.Get(name)
+		fmt.Fprintf(&b, "%g // %s\n", value, name)
+	}
+	return os.WriteFile(fileName, []byte(b.String()), 0644)
 }
 
 // 设置参数值
@@ -0,0 +1,148 @@
+package Data
+
+import (
+	"demo2/Watershed"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFile 在临时目录中写入测试文件，返回文件路径
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	fileName := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(fileName, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return fileName
+}
+
+// testParameter 返回合理的模型参数
+func testParameter() *Parameter {
+	return NewParameter(0.9, 20, 70, 0.15, 150, 0.3, 0.01, 30, 1.2, 0.3, 0.4, 0.2, 0.7, 0.98, 0.2, 24, 0.3)
+}
+
+func TestReadInitialStateFile(t *testing.T) {
+	fileName := writeFile(t, InitialStateFile, `2 // 单元流域数
+2 10 40 30 5 0.5 1 2 3 4 5
+1 20 70 60 30 1 0 0 0
+`)
+	states, err := ReadInitialStateFile(fileName, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &State{WU: 10, WL: 40, WD: 30, W: 80, S0: 5, FR: 0.5, QS: 1, QI: 2, QG: 3, QU: 6, O: []float64{4, 5}}
+	if !reflect.DeepEqual(states[1], want) {
+		t.Errorf("第2个单元流域的初始状态为%+v，应为%+v", states[1], want)
+	}
+	if states[0].W != 150 || states[0].O != nil {
+		t.Errorf("第1个单元流域的初始状态为%+v", states[0])
+	}
+}
+
+func TestReadInitialStateFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"空文件", "", "中的单元流域数与流域信息不一致，应为2个"},
+		{"单元流域数不符", "3\n1 0 0 0 0 0 0 0 0\n2 0 0 0 0 0 0 0 0\n", "中的单元流域数与流域信息不一致，应为2个"},
+		{"行数不符", "2\n1 0 0 0 0 0 0 0 0\n", "中的单元流域数与流域信息不一致，应为2个"},
+		{"列数不足", "2\n1 0 0 0 0 0 0 0\n2 0 0 0 0 0 0 0 0\n", "中每个单元流域至少需要9列，实际为8列"},
+		{"编号重复", "2\n1 0 0 0 0 0 0 0 0\n1 0 0 0 0 0 0 0 0\n", "中的单元流域编号1无效或重复"},
+		{"编号超出范围", "2\n1 0 0 0 0 0 0 0 0\n3 0 0 0 0 0 0 0 0\n", "中的单元流域编号3无效或重复"},
+		{"数值无效", "2\n1 0 0 x 0 0 0 0 0\n2 0 0 0 0 0 0 0 0\n", ":2:4: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName := writeFile(t, InitialStateFile, tt.content)
+			_, err := ReadInitialStateFile(fileName, 2)
+			if err == nil || !strings.HasPrefix(err.Error(), fileName+tt.err) {
+				t.Errorf("错误为%v，应以%q开头", err, fileName+tt.err)
+			}
+		})
+	}
+
+	var parseErr *Watershed.ParseError
+	_, err := ReadInitialStateFile(writeFile(t, InitialStateFile, "1\n1 a 0 0 0 0 0 0 0\n"), 1)
+	if !errors.As(err, &parseErr) || parseErr.Line != 2 || parseErr.Column != 2 {
+		t.Errorf("错误为%v，应为第2行第2列的ParseError", err)
+	}
+	if _, err := ReadInitialStateFile(filepath.Join(t.TempDir(), InitialStateFile), 1); err == nil {
+		t.Error("文件不存在时应返回错误")
+	}
+}
+
+func TestCheckInitial(t *testing.T) {
+	p := testParameter()
+	valid := State{WU: 10, WL: 40, WD: 30, S0: 5, FR: 0.5, Dt: 24, O: []float64{1}}
+	if err := valid.CheckInitial(p); err != nil {
+		t.Errorf("初始状态应与参数相容: %v", err)
+	}
+
+	invalid := State{WU: 25, WL: -1, WD: 70, S0: 31, FR: 1.5, QS: -1, Dt: 24, O: []float64{1, 2}}
+	err := invalid.CheckInitial(p)
+	if err == nil {
+		t.Fatal("初始状态与参数不相容时应返回错误")
+	}
+	for _, name := range []string{"WU=25", "WL=-1", "WD=70", "S0=31", "FR=1.5", "QS=-1", "子河段出流个数2"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("错误%q中缺少%s", err.Error(), name)
+		}
+	}
+}
+
+func TestClampTo(t *testing.T) {
+	s := State{WU: 25, WL: -1, WD: 70, S0: 31}
+	s.ClampTo(testParameter())
+	if s.WU != 20 || s.WL != 0 || s.WD != 60 || s.W != 80 || s.S0 != 30 {
+		t.Errorf("限制后的状态为%+v", s)
+	}
+}
+
+func TestCheckDt(t *testing.T) {
+	tests := []struct {
+		dt      float64
+		wantErr bool
+	}{
+		{24, false},
+		{1, false},
+		{0.5, false},
+		{3, false},
+		{5, true},  // 不能整除24
+		{7, true},  // 不能整除24
+		{0, true},  // 不为正
+		{-6, true}, // 不为正
+		{48, true}, // 超过24
+	}
+	for _, tt := range tests {
+		if err := CheckDt(tt.dt); (err != nil) != tt.wantErr {
+			t.Errorf("CheckDt(%g)的错误为%v", tt.dt, err)
+		}
+	}
+}
+
+func TestReachCount(t *testing.T) {
+	tests := []struct {
+		KE, dt float64
+		want   int // 为0时应返回错误
+	}{
+		{24, 24, 1},
+		{24, 6, 4},
+		{12, 4, 3},
+		{18, 12, 0}, // 不是时段长的整数倍
+		{30, 24, 0}, // 不是时段长的整数倍
+		{12, 24, 0}, // 子河段数小于1
+		{0, 6, 0},   // 子河段数为0
+	}
+	for _, tt := range tests {
+		n, err := ReachCount(tt.KE, tt.dt)
+		if tt.want == 0 && err == nil || tt.want != 0 && (err != nil || n != tt.want) {
+			t.Errorf("ReachCount(%g, %g) = %d，错误%v，应为%d", tt.KE, tt.dt, n, err, tt.want)
+		}
+	}
+}
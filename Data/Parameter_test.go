@@ -0,0 +1,65 @@
+package Data
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// WriteFile的输出应能由ReadFile读回
+func TestParameterRoundTrip(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "parameter.txt")
+	p := testParameter()
+	p.KG = 0.123456789
+	if err := p.WriteFile(fileName); err != nil {
+		t.Fatal(err)
+	}
+	var q Parameter
+	if err := q.ReadFile(fileName); err != nil {
+		t.Fatal(err)
+	}
+	if q != *p {
+		t.Errorf("读回的参数为%+v，应为%+v", q, *p)
+	}
+}
+
+func TestParameterReadFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     string // 错误信息中文件名之后的部分
+	}{
+		{"空文件", "", ": 应有17个参数，实际读取0个"},
+		{"参数过多", strings.Repeat("1\n", 18), ": 应有17个参数，实际读取18个"},
+		{"数值无效", "1 // KC\n20 x\n", ":2:2: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName := writeFile(t, "parameter.txt", tt.content)
+			p := testParameter()
+			err := p.ReadFile(fileName)
+			if err == nil || !strings.HasPrefix(err.Error(), fileName+tt.err) {
+				t.Errorf("错误为%v，应以%q开头", err, fileName+tt.err)
+			}
+			if *p != *testParameter() {
+				t.Error("读取失败时不应修改参数")
+			}
+		})
+	}
+}
+
+func TestGetSet(t *testing.T) {
+	p := testParameter()
+	if !p.Set("WM", 180) || p.WM != 180 {
+		t.Error("Set应设置WM")
+	}
+	if v, ok := p.Get("XE"); !ok || v != 0.3 {
+		t.Errorf("Get(XE) = %g, %v", v, ok)
+	}
+	if p.Set("FOO", 1) || IsParameter("FOO") || !IsParameter("KE") {
+		t.Error("未知的参数名应返回false")
+	}
+	if names := ParameterNames(); len(names) != NumParameters || names[0] != "KC" || names[NumParameters-1] != "XE" {
+		t.Errorf("参数名为%v", names)
+	}
+}
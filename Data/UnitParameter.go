@@ -0,0 +1,151 @@
+package Data
+
+import (
+	"bufio"
+	"demo2/Watershed"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// 单元流域参数文件名
+const UnitParameterFile = "unitparameter.txt"
+
+// UnitParameter 单元流域参数，未给出的参数继承流域参数
+type UnitParameter struct {
+	Group  string             // 所属分组，为空时不属于任何分组
+	Values map[string]float64 // 参数名到参数值
+}
+
+// UnitParameters 各单元流域参数，按单元流域顺序存储
+type UnitParameters []UnitParameter
+
+// ReadUnitParameters 读取单元流域参数文件，每行“//”之后为注释，格式为：
+//
+//	参数名 ...                            如“WM SM KG CS”
+//	单元流域编号 分组 参数值 ...          每个单元流域一行，分组或参数值为“-”时表示不分组或继承流域参数
+//
+// 文件中未列出的单元流域全部继承流域参数
+func ReadUnitParameters(filePath string, nw int) (UnitParameters, error) {
+	return ReadUnitParameterFile(filePath+UnitParameterFile, nw)
+}
+
+// ReadUnitParameterFile 读取指定的单元流域参数文件，格式同unitparameter.txt
+func ReadUnitParameterFile(fileName string, nw int) (UnitParameters, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	units := make(UnitParameters, nw)
+	var names []string
+	seen := make(map[int]bool)
+
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if i := strings.Index(text, "//"); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+
+		// 首行为参数名
+		if names == nil {
+			for i, name := range fields {
+				if !IsParameter(name) {
+					return nil, &Watershed.ParseError{File: fileName, Line: line, Column: i + 1, Err: fmt.Errorf("未知的参数名%s", name)}
+				}
+			}
+			names = fields
+			continue
+		}
+
+		if len(fields) != len(names)+2 {
+			return nil, &Watershed.ParseError{File: fileName, Line: line, Err: fmt.Errorf("应有%d列，实际为%d列", len(names)+2, len(fields))}
+		}
+		id, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, &Watershed.ParseError{File: fileName, Line: line, Column: 1, Err: err}
+		}
+		if id < 1 || id > nw {
+			return nil, &Watershed.ParseError{File: fileName, Line: line, Column: 1, Err: fmt.Errorf("单元流域编号%d超出范围[1, %d]", id, nw)}
+		}
+		if seen[id] {
+			return nil, &Watershed.ParseError{File: fileName, Line: line, Column: 1, Err: fmt.Errorf("单元流域编号%d重复", id)}
+		}
+		seen[id] = true
+
+		unit := UnitParameter{Values: make(map[string]float64)}
+		if fields[1] != "-" {
+			unit.Group = fields[1]
+		}
+		for i, name := range names {
+			if fields[i+2] == "-" {
+				continue
+			}
+			value, err := strconv.ParseFloat(fields[i+2], 64)
+			if err != nil {
+				return nil, &Watershed.ParseError{File: fileName, Line: line, Column: i + 3, Err: err}
+			}
+			unit.Values[name] = value
+		}
+		units[id-1] = unit
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, &Watershed.ParseError{File: fileName, Err: err}
+	}
+	if names == nil {
+		return nil, &Watershed.ParseError{File: fileName, Line: line, Err: fmt.Errorf("缺少参数名行")}
+	}
+
+	return units, nil
+}
+
+// HasGroup 判断是否有单元流域属于给定分组，分组名为空时返回false
+func (u UnitParameters) HasGroup(group string) bool {
+	if group == "" {
+		return false
+	}
+	for _, unit := range u {
+		if unit.Group == group {
+			return true
+		}
+	}
+	return false
+}
+
+// SetForAll 判断是否每个单元流域都给出了该参数，此时流域参数中的该参数对单元流域不起作用
+func (u UnitParameters) SetForAll(name string) bool {
+	for _, unit := range u {
+		if _, ok := unit.Values[name]; !ok {
+			return false
+		}
+	}
+	return len(u) > 0
+}
+
+// Resolve 返回各单元流域的参数：以流域参数basin为基础，先应用文件中给出的单元流域参数，
+// 再应用分组参数groups[分组][参数名]，分组参数用于率定时同一分组的单元流域取相同值
+func (u UnitParameters) Resolve(basin *Parameter, groups map[string]map[string]float64) []*Parameter {
+	parameters := make([]*Parameter, len(u))
+	for w, unit := range u {
+		p := *basin
+		for name, value := range unit.Values {
+			p.Set(name, value)
+		}
+		for name, value := range groups[unit.Group] {
+			if unit.Group != "" {
+				p.Set(name, value)
+			}
+		}
+		parameters[w] = &p
+	}
+	return parameters
+}
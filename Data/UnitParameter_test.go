@@ -0,0 +1,102 @@
+package Data
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadUnitParameterFile(t *testing.T) {
+	fileName := writeFile(t, UnitParameterFile, `WM SM KG // 参数名
+1 upper 160 - 0.35
+3 - - 40 -
+`)
+	units, err := ReadUnitParameterFile(fileName, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(units) != 3 {
+		t.Fatalf("单元流域参数有%d个，应为3个", len(units))
+	}
+	if units[0].Group != "upper" || len(units[0].Values) != 2 || units[0].Values["WM"] != 160 || units[0].Values["KG"] != 0.35 {
+		t.Errorf("第1个单元流域参数为%+v", units[0])
+	}
+	if units[1].Group != "" || units[1].Values != nil {
+		t.Errorf("未列出的第2个单元流域参数为%+v，应继承流域参数", units[1])
+	}
+	if units[2].Group != "" || len(units[2].Values) != 1 || units[2].Values["SM"] != 40 {
+		t.Errorf("第3个单元流域参数为%+v", units[2])
+	}
+	if !units.HasGroup("upper") || units.HasGroup("lower") {
+		t.Error("HasGroup结果有误")
+	}
+}
+
+func TestReadUnitParameterFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     string // 错误信息中文件名之后的部分
+	}{
+		{"空文件", "", ": 缺少参数名行"},
+		{"未知的参数名", "WM FOO\n", ":1:2: 未知的参数名FOO"},
+		{"列数不符", "WM SM\n1 - 160\n", ":2: 应有4列，实际为3列"},
+		{"编号无效", "WM\nx - 160\n", ":2:1: "},
+		{"编号超出范围", "WM\n4 - 160\n", ":2:1: 单元流域编号4超出范围[1, 3]"},
+		{"编号重复", "WM\n1 - 160\n1 - 170\n", ":3:1: 单元流域编号1重复"},
+		{"参数值无效", "WM SM\n1 - 160 x\n", ":2:4: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName := writeFile(t, UnitParameterFile, tt.content)
+			_, err := ReadUnitParameterFile(fileName, 3)
+			if err == nil || !strings.HasPrefix(err.Error(), fileName+tt.err) {
+				t.Errorf("错误为%v，应以%q开头", err, fileName+tt.err)
+			}
+		})
+	}
+	if _, err := ReadUnitParameterFile(filepath.Join(t.TempDir(), UnitParameterFile), 3); err == nil {
+		t.Error("文件不存在时应返回错误")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	units := UnitParameters{
+		{Group: "upper", Values: map[string]float64{"WM": 160}},
+		{Values: map[string]float64{"SM": 40}},
+		{Group: "upper"},
+	}
+	basin := testParameter()
+	groups := map[string]map[string]float64{"upper": {"WM": 180, "KG": 0.35}, "": {"KG": 0.1}}
+	parameters := units.Resolve(basin, groups)
+
+	// 分组参数优先于文件中的单元流域参数，不分组的单元流域不受分组参数影响
+	if p := parameters[0]; p.WM != 180 || p.KG != 0.35 || p.SM != basin.SM {
+		t.Errorf("第1个单元流域参数为%+v", p)
+	}
+	if p := parameters[1]; p.WM != basin.WM || p.KG != basin.KG || p.SM != 40 {
+		t.Errorf("第2个单元流域参数为%+v", p)
+	}
+	if p := parameters[2]; p.WM != 180 || p.KG != 0.35 {
+		t.Errorf("第3个单元流域参数为%+v", p)
+	}
+	if basin.WM != 150 {
+		t.Error("Resolve不应修改流域参数")
+	}
+}
+
+func TestHasGroupAndSetForAll(t *testing.T) {
+	units := UnitParameters{
+		{Group: "upper", Values: map[string]float64{"WM": 160, "SM": 40}},
+		{Values: map[string]float64{"WM": 170}},
+	}
+	if !units.HasGroup("upper") || units.HasGroup("") || units.HasGroup("lower") {
+		t.Error("HasGroup结果有误，空分组名应返回false")
+	}
+	if !units.SetForAll("WM") || units.SetForAll("SM") || units.SetForAll("KG") {
+		t.Error("SetForAll结果有误")
+	}
+	if (UnitParameters{}).SetForAll("WM") {
+		t.Error("没有单元流域时SetForAll应返回false")
+	}
+}
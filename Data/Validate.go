@@ -0,0 +1,122 @@
+package Data
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ParameterError 模型参数不满足物理意义上的取值范围或参数间约束
+type ParameterError struct {
+	Name   string  // 参数名或约束中的表达式，如“WM”“KI+KG”
+	Value  float64 // 参数或表达式的值
+	Rule   string  // 应满足的条件
+	Excess float64 // 超出可行域的量，参数率定中用作罚函数
+}
+
+func (e *ParameterError) Error() string {
+	return fmt.Sprintf("%s=%g不满足%s", e.Name, e.Value, e.Rule)
+}
+
+// Violations 返回参数的全部不合理之处，参数合理时返回nil
+// 检查各参数的取值范围及参数间约束：WM ≥ UM+LM（深层张力水容量非负）、KI+KG < 1（出流系数换算有意义）
+func (p *Parameter) Violations() []*ParameterError {
+	var errs []*ParameterError
+	add := func(name string, value float64, rule string, excess float64) {
+		errs = append(errs, &ParameterError{Name: name, Value: value, Rule: rule, Excess: excess})
+	}
+	// 下限lower，lowerOpen为真时不能取到下限；上限同理，无上限时为+Inf
+	check := func(name string, value, lower float64, lowerOpen bool, upper float64, upperOpen bool) {
+		switch {
+		case math.IsNaN(value) || math.IsInf(value, 0):
+			add(name, value, "为有限值", 1)
+		case value < lower || lowerOpen && value == lower:
+			add(name, value, interval(lower, lowerOpen, upper, upperOpen), lower-value)
+		case value > upper || upperOpen && value == upper:
+			add(name, value, interval(lower, lowerOpen, upper, upperOpen), value-upper)
+		}
+	}
+	inf := math.Inf(1)
+
+	check("KC", p.KC, 0, true, inf, false)
+	check("UM", p.UM, 0, true, inf, false)
+	check("LM", p.LM, 0, true, inf, false)
+	check("C", p.C, 0, false, 1, false)
+	check("WM", p.WM, 0, true, inf, false)
+	check("B", p.B, 0, false, inf, false)
+	check("IM", p.IM, 0, false, 1, true)
+	check("SM", p.SM, 0, true, inf, false)
+	check("EX", p.EX, 0, false, inf, false)
+	check("KG", p.KG, 0, false, 1, true)
+	check("KI", p.KI, 0, true, 1, true)
+	check("CS", p.CS, 0, false, 1, true)
+	check("CI", p.CI, 0, false, 1, true)
+	check("CG", p.CG, 0, false, 1, true)
+	check("CR", p.CR, 0, false, 1, true)
+	check("KE", p.KE, 0, true, inf, false)
+	check("XE", p.XE, 0, false, 0.5, false)
+
+	// 参数间约束
+	if p.WM < p.UM+p.LM {
+		add("UM+LM", p.UM+p.LM, fmt.Sprintf("≤ WM=%g", p.WM), p.UM+p.LM-p.WM)
+	}
+	if p.KI+p.KG >= 1 {
+		add("KI+KG", p.KI+p.KG, "< 1", p.KI+p.KG-1)
+	}
+	return errs
+}
+
+// Validate 检查参数的取值范围及参数间约束，返回全部不合理之处，参数合理时返回nil
+func (p *Parameter) Validate() error {
+	var errs []error
+	for _, e := range p.Violations() {
+		errs = append(errs, e)
+	}
+	return errors.Join(errs...)
+}
+
+// Infeasibility 返回参数超出可行域的总量，参数合理时为0
+func (p *Parameter) Infeasibility() float64 {
+	total := 0.0
+	for _, e := range p.Violations() {
+		total += e.Excess
+	}
+	return total
+}
+
+// 新安江模型参数的常用取值范围，超出时参数仍可计算，但物理意义存疑
+var typicalRanges = []struct {
+	name         string
+	value        func(p *Parameter) float64
+	lower, upper float64
+}{
+	{"UM", func(p *Parameter) float64 { return p.UM }, 10, 50},
+	{"LM", func(p *Parameter) float64 { return p.LM }, 60, 90},
+	{"C", func(p *Parameter) float64 { return p.C }, 0.10, 0.20},
+	{"WM", func(p *Parameter) float64 { return p.WM }, 120, 200},
+	{"B", func(p *Parameter) float64 { return p.B }, 0.1, 0.4},
+	{"EX", func(p *Parameter) float64 { return p.EX }, 1.0, 1.5},
+}
+
+// CheckTypical 检查参数是否在常用取值范围内，返回全部超出常用范围的参数，仅作提示
+func (p *Parameter) CheckTypical() error {
+	var errs []error
+	for _, r := range typicalRanges {
+		if value := r.value(p); value < r.lower || value > r.upper {
+			errs = append(errs, fmt.Errorf("%s=%g超出常用范围[%g, %g]", r.name, value, r.lower, r.upper))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// interval 返回区间的文字表示
+func interval(lower float64, lowerOpen bool, upper float64, upperOpen bool) string {
+	left, right := "[", "]"
+	if lowerOpen {
+		left = "("
+	}
+	if upperOpen || math.IsInf(upper, 1) {
+		right = ")"
+	}
+	return fmt.Sprintf("%s%g, %g%s", left, lower, upper, right)
+}
@@ -0,0 +1,75 @@
+package Data
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestViolations(t *testing.T) {
+	if errs := testParameter().Violations(); errs != nil {
+		t.Errorf("合理的参数不应有不合理之处，实际为%v", errs)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(p *Parameter)
+		want   string  // 不合理之处的名称
+		excess float64 // 超出可行域的量
+	}{
+		{"KC为0", func(p *Parameter) { p.KC = 0 }, "KC", 0},
+		{"C大于1", func(p *Parameter) { p.C = 1.5 }, "C", 0.5},
+		{"IM取到上限", func(p *Parameter) { p.IM = 1 }, "IM", 0},
+		{"B为负", func(p *Parameter) { p.B = -0.2 }, "B", 0.2},
+		{"XE超出0.5", func(p *Parameter) { p.XE = 0.75 }, "XE", 0.25},
+		{"WM为NaN", func(p *Parameter) { p.WM = math.NaN() }, "WM", 1},
+		{"UM+LM超出WM", func(p *Parameter) { p.WM = 80 }, "UM+LM", 10},
+		{"KI+KG不小于1", func(p *Parameter) { p.KI, p.KG = 0.5, 0.75 }, "KI+KG", 0.25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testParameter()
+			tt.modify(p)
+			errs := p.Violations()
+			if len(errs) != 1 || errs[0].Name != tt.want || errs[0].Excess != tt.excess {
+				t.Fatalf("不合理之处为%v，应只有%s", errs, tt.want)
+			}
+			if p.Validate() == nil {
+				t.Error("Validate应返回错误")
+			}
+			if got := p.Infeasibility(); got != tt.excess {
+				t.Errorf("超出可行域的总量为%g，应为%g", got, tt.excess)
+			}
+		})
+	}
+}
+
+// 多处不合理时全部返回
+func TestValidateAll(t *testing.T) {
+	p := testParameter()
+	p.C, p.XE = -0.1, 0.6
+	err := p.Validate()
+	if err == nil {
+		t.Fatal("参数不合理时应返回错误")
+	}
+	for _, want := range []string{"C=-0.1不满足[0, 1]", "XE=0.6不满足[0, 0.5]"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("错误%q中缺少%q", err.Error(), want)
+		}
+	}
+}
+
+func TestCheckTypical(t *testing.T) {
+	p := testParameter()
+	if err := p.CheckTypical(); err != nil {
+		t.Errorf("参数均在常用范围内，实际为%v", err)
+	}
+	p.WM, p.EX = 250, 1.5
+	err := p.CheckTypical()
+	if err == nil || !strings.Contains(err.Error(), "WM=250超出常用范围[120, 200]") || strings.Contains(err.Error(), "EX") {
+		t.Errorf("错误为%v，应只有WM超出常用范围", err)
+	}
+	if p.Validate() != nil {
+		t.Error("超出常用范围的参数仍应通过Validate")
+	}
+}
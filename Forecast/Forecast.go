@@ -0,0 +1,246 @@
+package Forecast
+
+import (
+	"demo2/Correction"
+	"demo2/Data"
+	"demo2/Model"
+	"demo2/Network"
+	"demo2/Watershed"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Scenario 预报降雨情景，按雨量站给出预见期逐时段降雨
+type Scenario struct {
+	Name string      // 情景名称
+	P    [][]float64 // 预见期逐时段各雨量站降雨，mm
+}
+
+// ReadScenario 读取预报降雨文件，格式与P.txt相同，情景名称取文件名
+func ReadScenario(fileName string) (*Scenario, error) {
+	series, err := Watershed.ReadSeries(fileName)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
+	return &Scenario{Name: name, P: series.Values}, nil
+}
+
+// Hydrograph 一个情景的预报流量过程及其特征值
+type Hydrograph struct {
+	Scenario string    // 情景名称
+	Start    time.Time // 预见期首时段时间，零值表示没有时间信息
+	Step     time.Duration
+	Q        []float64 // 预见期流域出口断面流量过程，m3/s，实时校正时为校正后的过程
+	Raw      []float64 // 预见期校正前的流量过程，m3/s
+
+	Coef        []float64               // AR校正的误差模型系数
+	Ratio       float64                 // 状态修正系数
+	Comparisons []Correction.Comparison // 校正前后的评价指标
+
+	Peak     float64 // 洪峰流量，m3/s
+	PeakStep int     // 峰现时段，预见期第几个时段，从1计
+	Volume   float64 // 预见期洪量，万m3
+}
+
+// PeakTime 返回峰现时间，没有时间信息时为零值
+func (h *Hydrograph) PeakTime() time.Time {
+	if h.Start.IsZero() {
+		return time.Time{}
+	}
+	return h.Start.Add(time.Duration(h.PeakStep-1) * h.Step)
+}
+
+// Forecast 实时洪水预报：从发布时刻之前的状态快照热启动，先以实测降雨、蒸发计算至发布时刻，
+// 再以各情景的预报降雨计算预见期
+type Forecast struct {
+	Watershed *Watershed.Watershed // 流域信息
+	Parameter *Data.Parameter      // 模型参数
+	Units     []*Data.Parameter    // 各单元流域参数，为nil时共用模型参数
+	Network   *Network.Network     // 河网拓扑，为nil时各单元流域出流分别演算至流域出口
+	Snapshot  *Model.Snapshot      // 状态快照，其后一个时段为实测期首时段
+	Observed  *Watershed.IO        // 快照之后至发布时刻的各站实测降雨、蒸发，可以没有记录
+	EM        [][]float64          // 预见期逐时段各蒸发站蒸发，mm，为nil时取实测期末时段的蒸发
+
+	Correction   Correction.Options // 实时校正设置，使用Observed.Q中的实测流量
+	LeadObserved []float64          // 预见期实测流量，m3/s，给出时评价校正前后的预报，可为nil
+}
+
+// Issue 返回预见期首时段时间，没有时间信息时为零值
+func (f *Forecast) Issue() time.Time {
+	if !f.Observed.HasTime() {
+		return time.Time{}
+	}
+	return f.Observed.Time(f.Observed.Nrows)
+}
+
+// Run 计算一个情景的预报流量过程
+func (f *Forecast) Run(scenario *Scenario) (*Hydrograph, error) {
+	lead := len(scenario.P)
+	if lead == 0 {
+		return nil, fmt.Errorf("情景%s没有预报降雨", scenario.Name)
+	}
+
+	em, err := f.leadEvaporation(lead)
+	if err != nil {
+		return nil, err
+	}
+
+	// 实测期与预见期的降雨、蒸发首尾相接
+	io := &Watershed.IO{
+		Nrows: f.Observed.Nrows + lead,
+		Ncols: f.Watershed.NumRainfallStation,
+		Mp:    append(append([][]float64(nil), f.Observed.Mp...), scenario.P...),
+		MEM:   append(append([][]float64(nil), f.Observed.MEM...), em...),
+		Start: f.Observed.Start,
+		Step:  f.Observed.Step,
+	}
+	watershed := *f.Watershed
+	if err := watershed.Calculate(io); err != nil {
+		return nil, fmt.Errorf("情景%s: %w", scenario.Name, err)
+	}
+
+	model, err := Model.NewModel(&watershed, f.Parameter)
+	if err != nil {
+		return nil, err
+	}
+	if err := model.SetDt(f.Snapshot.Dt); err != nil {
+		return nil, err
+	}
+	if err := model.SetNetwork(f.Network); err != nil {
+		return nil, err
+	}
+	if err := model.SetUnitParameters(f.Units); err != nil {
+		return nil, err
+	}
+	if err := model.Restore(f.Snapshot); err != nil {
+		return nil, err
+	}
+
+	// 计算至发布时刻并保存发布时刻的状态，再计算预见期
+	nObs := f.Observed.Nrows
+	simulated := make([]float64, io.Nrows)
+	for t := 0; t < nObs; t++ {
+		simulated[t] = model.Step(t)
+	}
+	issue := model.Snapshot(nObs)
+	for t := nObs; t < io.Nrows; t++ {
+		simulated[t] = model.Step(t)
+	}
+
+	h := &Hydrograph{
+		Scenario: scenario.Name,
+		Start:    f.Issue(),
+		Step:     f.Observed.Step,
+		Q:        simulated[nObs:],
+		Raw:      simulated[nObs:],
+		Ratio:    1,
+	}
+	if err := f.correct(model, issue, simulated, h); err != nil {
+		return nil, fmt.Errorf("情景%s: %w", scenario.Name, err)
+	}
+	for t, q := range h.Q {
+		if t == 0 || q > h.Peak {
+			h.Peak, h.PeakStep = q, t+1
+		}
+		h.Volume += q * f.Snapshot.Dt * 3600 / 1e4
+	}
+	return h, nil
+}
+
+// correct 以发布时刻之前的实测流量对预见期流量进行实时校正，issue为发布时刻的状态
+func (f *Forecast) correct(model *Model.Model, issue *Model.Snapshot, simulated []float64, h *Hydrograph) error {
+	nObs := f.Observed.Nrows
+	switch f.Correction.Method {
+	case Correction.None:
+		return nil
+	case Correction.AR:
+		if nObs == 0 || len(f.Observed.Q) < nObs {
+			return fmt.Errorf("实时校正需要发布时刻之前的实测流量")
+		}
+		sim, obs := simulated[:nObs], f.Observed.Q[:nObs]
+		e := Correction.Residuals(sim, obs)
+		coef, err := Correction.FitAR(e, f.Correction.Order)
+		if err != nil {
+			return err
+		}
+		h.Coef = coef
+
+		predicted := Correction.PredictAR(coef, e[max(nObs-len(coef), 0):], len(h.Raw))
+		h.Q = make([]float64, len(h.Raw))
+		for t := range h.Q {
+			h.Q[t] = math.Max(h.Raw[t]+predicted[t], 0)
+		}
+
+		fitted := Correction.OneStepAR(coef, e)
+		for t := range fitted {
+			fitted[t] += sim[t]
+		}
+		h.Comparisons = append(h.Comparisons, Correction.Comparison{
+			Period: "发布时刻之前（一步预报）",
+			Before: Correction.Evaluate(sim, obs),
+			After:  Correction.Evaluate(fitted, obs),
+		})
+	case Correction.Routing, Correction.Storage:
+		if nObs == 0 || len(f.Observed.Q) < nObs {
+			return fmt.Errorf("实时校正需要发布时刻之前的实测流量")
+		}
+		h.Ratio = Correction.Ratio(simulated[:nObs], f.Observed.Q[:nObs], f.Correction.Window)
+		if f.Correction.Method == Correction.Routing {
+			Correction.UpdateRouting(issue.States, issue.Reaches, h.Ratio)
+		} else {
+			parameters := f.Units
+			if parameters == nil {
+				parameters = make([]*Data.Parameter, len(issue.States))
+				for w := range parameters {
+					parameters[w] = f.Parameter
+				}
+			}
+			Correction.UpdateStorage(issue.States, parameters, h.Ratio)
+		}
+
+		// 以修正后的状态重新计算预见期
+		if err := model.Restore(issue); err != nil {
+			return err
+		}
+		h.Q = make([]float64, len(h.Raw))
+		for t := range h.Q {
+			h.Q[t] = model.Step(nObs + t)
+		}
+	default:
+		return fmt.Errorf("未知的校正方法%s", f.Correction.Method)
+	}
+
+	if f.LeadObserved != nil {
+		n := min(len(f.LeadObserved), len(h.Q))
+		h.Comparisons = append(h.Comparisons, Correction.Comparison{
+			Period: "预见期",
+			Before: Correction.Evaluate(h.Raw[:n], f.LeadObserved[:n]),
+			After:  Correction.Evaluate(h.Q[:n], f.LeadObserved[:n]),
+		})
+	}
+	return nil
+}
+
+// leadEvaporation 返回预见期逐时段各蒸发站蒸发
+func (f *Forecast) leadEvaporation(lead int) ([][]float64, error) {
+	if f.EM != nil {
+		if len(f.EM) < lead {
+			return nil, fmt.Errorf("预见期蒸发%d个时段，少于预见期%d个时段", len(f.EM), lead)
+		}
+		return f.EM[:lead], nil
+	}
+	if f.Observed.Nrows == 0 {
+		return nil, fmt.Errorf("没有实测蒸发，须给出预见期蒸发")
+	}
+
+	last := f.Observed.MEM[f.Observed.Nrows-1]
+	em := make([][]float64, lead)
+	for t := range em {
+		em[t] = last
+	}
+	return em, nil
+}
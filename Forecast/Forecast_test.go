@@ -0,0 +1,231 @@
+package Forecast
+
+import (
+	"demo2/Correction"
+	"demo2/Data"
+	"demo2/Model"
+	"demo2/Watershed"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 快照时段、实测期时段数及预见期时段数
+const (
+	snapshotStep = 1000
+	numObserved  = 20
+	numLead      = 10
+)
+
+// example 以IOexamples的前snapshotStep个时段计算快照，其后numObserved个时段作为实测期，
+// 返回预报设置、预见期的降雨情景及不中断计算的出口流量过程
+func example(t *testing.T) (*Forecast, *Scenario, []float64) {
+	t.Helper()
+	path := "../IOexamples/"
+	watershed := &Watershed.Watershed{}
+	io := &Watershed.IO{}
+	parameter := &Data.Parameter{}
+	if err := watershed.ReadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := io.ReadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := watershed.Calculate(io); err != nil {
+		t.Fatal(err)
+	}
+	if err := parameter.ReadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := Model.NewModel(watershed, parameter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.SetSnapshotSteps([]int{snapshotStep})
+	result, err := m.Run(m.NumSteps())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issue := snapshotStep + numObserved
+	f := &Forecast{
+		Watershed: watershed,
+		Parameter: parameter,
+		Snapshot:  result.Snapshots[0],
+		Observed: &Watershed.IO{
+			Nrows: numObserved,
+			Ncols: io.Ncols,
+			Mp:    io.Mp[snapshotStep:issue],
+			MEM:   io.MEM[snapshotStep:issue],
+		},
+		EM: io.MEM[issue : issue+numLead],
+	}
+	scenario := &Scenario{Name: "实测", P: io.Mp[issue : issue+numLead]}
+	return f, scenario, result.Q[snapshotStep : issue+numLead]
+}
+
+func TestReadScenario(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "暴雨.txt")
+	if err := os.WriteFile(fileName, []byte("2 2\n10 20\n5 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := ReadScenario(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "暴雨" || !reflect.DeepEqual(s.P, [][]float64{{10, 20}, {5, 0}}) {
+		t.Errorf("情景为%+v", s)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		err     string // 错误信息中文件名之后的部分
+	}{
+		{"空文件", "", ":1: "},
+		{"列数不符", "2 2\n10 20\n5\n", ":3: 应有2列，实际为1列"},
+		{"数值无效", "1 2\n10 x\n", ":2:2: "},
+		{"行数不足", "3 1\n1\n2\n", ":4: 应有3行数据，实际为2行"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(fileName, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := ReadScenario(fileName)
+			var parseErr *Watershed.ParseError
+			if !errors.As(err, &parseErr) || !strings.HasPrefix(err.Error(), fileName+tt.err) {
+				t.Errorf("错误为%v，应以%q开头", err, fileName+tt.err)
+			}
+		})
+	}
+}
+
+// 从快照热启动，以实测降雨作为情景时，预见期流量应与不中断的计算完全相同
+func TestRun(t *testing.T) {
+	f, scenario, want := example(t)
+	h, err := f.Run(scenario)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(h.Q, want[numObserved:]) || !reflect.DeepEqual(h.Raw, h.Q) {
+		t.Fatalf("预见期流量为%v，应为%v", h.Q, want[numObserved:])
+	}
+	if h.Scenario != "实测" || h.Ratio != 1 || h.Coef != nil || h.Comparisons != nil {
+		t.Errorf("不校正时预报结果为%+v", h)
+	}
+
+	peak, peakStep, volume := 0.0, 0, 0.0
+	for i, q := range h.Q {
+		if q > peak {
+			peak, peakStep = q, i+1
+		}
+		volume += q * 24 * 3600 / 1e4
+	}
+	if h.Peak != peak || h.PeakStep != peakStep || math.Abs(h.Volume-volume) > 1e-9*volume {
+		t.Errorf("洪峰%g、峰现时段%d、洪量%g，应为%g、%d、%g", h.Peak, h.PeakStep, h.Volume, peak, peakStep, volume)
+	}
+	if !h.PeakTime().IsZero() || !f.Issue().IsZero() {
+		t.Error("没有时间信息时峰现时间及发布时刻应为零值")
+	}
+
+	if _, err := f.Run(&Scenario{Name: "空"}); err == nil {
+		t.Error("没有预报降雨时应返回错误")
+	}
+}
+
+func TestPeakTime(t *testing.T) {
+	start := time.Date(2000, 7, 1, 8, 0, 0, 0, time.UTC)
+	h := &Hydrograph{Start: start, Step: 6 * time.Hour, PeakStep: 3}
+	if got := h.PeakTime(); !got.Equal(start.Add(12 * time.Hour)) {
+		t.Errorf("峰现时间为%v", got)
+	}
+}
+
+func TestCorrect(t *testing.T) {
+	f, scenario, simulated := example(t)
+
+	// 实测流量比模拟流量系统性偏大一倍，并带有自相关的误差
+	observed := make([]float64, len(simulated))
+	for i, q := range simulated {
+		observed[i] = 2*q + 0.5*math.Sin(float64(i)/3)
+	}
+	f.Observed.Q = observed[:numObserved]
+	f.LeadObserved = observed[numObserved:]
+
+	tests := []struct {
+		options     Correction.Options
+		comparisons int
+	}{
+		{Correction.Options{Method: Correction.AR, Order: 2}, 2},
+		{Correction.Options{Method: Correction.Routing, Window: 5}, 1},
+		{Correction.Options{Method: Correction.Storage, Window: 5}, 1},
+	}
+	for _, tt := range tests {
+		f.Correction = tt.options
+		h, err := f.Run(scenario)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.options.Method, err)
+		}
+		if !reflect.DeepEqual(h.Raw, simulated[numObserved:]) {
+			t.Errorf("%s: 校正前的流量应与不校正时相同", tt.options.Method)
+		}
+		if len(h.Comparisons) != tt.comparisons {
+			t.Fatalf("%s: 评价指标有%d组，应为%d组", tt.options.Method, len(h.Comparisons), tt.comparisons)
+		}
+		lead := h.Comparisons[len(h.Comparisons)-1]
+		if lead.Period != "预见期" || lead.After.RMSE >= lead.Before.RMSE {
+			t.Errorf("%s: 预见期校正前后的评价指标为%+v", tt.options.Method, lead)
+		}
+		switch tt.options.Method {
+		case Correction.AR:
+			if len(h.Coef) != 2 || h.Ratio != 1 {
+				t.Errorf("AR校正的系数为%v，状态修正系数为%g", h.Coef, h.Ratio)
+			}
+		default:
+			if h.Ratio <= 1.5 || h.Ratio > 2.5 {
+				t.Errorf("%s: 状态修正系数为%g，应接近2", tt.options.Method, h.Ratio)
+			}
+		}
+	}
+
+	f.Correction = Correction.Options{Method: Correction.Routing, Window: 5}
+	f.Observed.Q = nil
+	if _, err := f.Run(scenario); err == nil {
+		t.Error("没有实测流量时不能实时校正")
+	}
+	f.Correction = Correction.Options{Method: "kalman"}
+	if _, err := f.Run(scenario); err == nil {
+		t.Error("未知的校正方法应返回错误")
+	}
+}
+
+func TestLeadEvaporation(t *testing.T) {
+	f := &Forecast{Observed: &Watershed.IO{Nrows: 2, MEM: [][]float64{{1, 2}, {3, 4}}}}
+	em, err := f.leadEvaporation(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(em, [][]float64{{3, 4}, {3, 4}, {3, 4}}) {
+		t.Errorf("未给出预见期蒸发时应取实测期末时段的蒸发，实际为%v", em)
+	}
+
+	f.EM = [][]float64{{5, 6}, {7, 8}}
+	if em, err := f.leadEvaporation(1); err != nil || !reflect.DeepEqual(em, [][]float64{{5, 6}}) {
+		t.Errorf("预见期蒸发为%v、%v", em, err)
+	}
+	if _, err := f.leadEvaporation(3); err == nil {
+		t.Error("预见期蒸发不足时应返回错误")
+	}
+
+	f = &Forecast{Observed: &Watershed.IO{}}
+	if _, err := f.leadEvaporation(1); err == nil {
+		t.Error("没有实测蒸发且未给出预见期蒸发时应返回错误")
+	}
+}
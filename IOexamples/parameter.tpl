@@ -1,20 +0,0 @@
-KC
-UM
-60
-0.18
-
-120
-0.3
-0.01
-
-SM
-1.5
-0.13
-KI
-
-0.2
-CI
-CG
-CR
-24
-0.4
\ No newline at end of file
@@ -0,0 +1,247 @@
+package Model
+
+import (
+	"demo2/Data"
+	"fmt"
+	"io"
+	"math"
+)
+
+// 保存的超限记录个数上限，超出部分只计数
+const maxViolations = 100
+
+// Budget 水量平衡各项，均折算为单元流域（或全流域）面积上的水深，mm
+// 闭合误差 = 入流 − 出流 − 蓄量变化，模型各环节守恒时为0
+// 张力水、自由水及RS、RI、RG为透水面积上的水深，按透水面积比例1−IM折算；不透水面积上净雨全部产流RIM
+type Budget struct {
+	P       float64 // 降雨量
+	E       float64 // 蒸散发量，含不透水面积上的蒸发 IM·P − RIM
+	R       float64 // 产流量 (1−IM)·R + RIM
+	Runoff  float64 // 进入坡面汇流的径流 (1−IM)·(RS+RI+RG) + RIM
+	Inflow  float64 // 单元流域出口流量 QU 折算的水深
+	Outflow float64 // 流至流域出口断面的水量
+
+	DeltaTension float64 // 张力水蓄量变化
+	DeltaFree    float64 // 自由水蓄量变化，按 S·FR 计
+	DeltaRouting float64 // 坡面及河网汇流蓄量变化（线性水库）
+	DeltaChannel float64 // 河道蓄量变化（马斯京根）
+
+	Soil    float64 // 蒸散发、产流环节闭合误差，P − E − R − ΔW
+	Free    float64 // 分水源环节闭合误差，R − Runoff − Δ(S·FR)
+	Routing float64 // 汇流环节闭合误差，Runoff − QU − ΔSr
+	Channel float64 // 河道汇流环节闭合误差，QU − O − ΔSc
+}
+
+// Residual 返回总闭合误差，mm
+func (b *Budget) Residual() float64 {
+	return b.Soil + b.Free + b.Routing + b.Channel
+}
+
+// add 累加各项，weight为面积权重
+func (b *Budget) add(o *Budget, weight float64) {
+	b.P += o.P * weight
+	b.E += o.E * weight
+	b.R += o.R * weight
+	b.Runoff += o.Runoff * weight
+	b.Inflow += o.Inflow * weight
+	b.Outflow += o.Outflow * weight
+	b.DeltaTension += o.DeltaTension * weight
+	b.DeltaFree += o.DeltaFree * weight
+	b.DeltaRouting += o.DeltaRouting * weight
+	b.DeltaChannel += o.DeltaChannel * weight
+	b.Soil += o.Soil * weight
+	b.Free += o.Free * weight
+	b.Routing += o.Routing * weight
+	b.Channel += o.Channel * weight
+}
+
+// Violation 闭合误差超过容许值的记录
+type Violation struct {
+	Step     int     // 时段，从0计
+	Unit     int     // 单元流域，从0计，-1表示河网
+	Residual float64 // 闭合误差，mm
+}
+
+// Balance 水量平衡核算结果
+type Balance struct {
+	Tolerance     float64     // 单元流域单时段闭合误差容许值，mm
+	Steps         []Budget    // 全流域逐时段水量平衡
+	Units         []Budget    // 各单元流域累计水量平衡
+	Total         Budget      // 全流域累计水量平衡
+	Violations    []Violation // 超过容许值的记录，最多保存maxViolations条
+	NumViolations int         // 超过容许值的记录总数
+}
+
+// Err 有闭合误差超过容许值时返回错误
+func (b *Balance) Err() error {
+	if b.NumViolations == 0 {
+		return nil
+	}
+	v := b.Violations[0]
+	where := "河网"
+	if v.Unit >= 0 {
+		where = fmt.Sprintf("第%d个单元流域", v.Unit+1)
+	}
+	return fmt.Errorf("水量平衡闭合误差有%d处超过容许值%gmm，首次出现在第%d时段%s，误差%.6gmm",
+		b.NumViolations, b.Tolerance, v.Step+1, where, v.Residual)
+}
+
+// WriteTable 输出全流域及各单元流域的累计水量平衡表
+func (b *Balance) WriteTable(w io.Writer) {
+	fmt.Fprintln(w, "单元\tP\tE\tR\t出流\tΔ张力水\tΔ自由水\tΔ汇流\tΔ河道\t产流误差\t分水源误差\t汇流误差\t河道误差\t总误差")
+	row := func(name string, u *Budget) {
+		fmt.Fprintf(w, "%s\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3g\t%.3g\t%.3g\t%.3g\t%.3g\n", name,
+			u.P, u.E, u.R, u.Outflow, u.DeltaTension, u.DeltaFree, u.DeltaRouting, u.DeltaChannel,
+			u.Soil, u.Free, u.Routing, u.Channel, u.Residual())
+	}
+	for i := range b.Units {
+		row(fmt.Sprintf("%d", i+1), &b.Units[i])
+	}
+	row("全流域", &b.Total)
+	fmt.Fprintf(w, "闭合误差超过%gmm的单元时段: %d\n", b.Tolerance, b.NumViolations)
+}
+
+// SetBalance 开启水量平衡核算，tolerance为单元流域单时段闭合误差的容许值（mm），结果保存在Result.Balance中
+// 核算按默认计算流程的蓄量定义进行，tolerance小于0时关闭核算
+func (m *Model) SetBalance(tolerance float64) {
+	m.balanceTol = tolerance
+}
+
+// storage 单元流域各蓄量，mm
+type storage struct {
+	tension, free, routing, channel float64
+}
+
+// unitStorage 返回第w个单元流域的各蓄量
+func (m *Model) unitStorage(w int, state *Data.State) storage {
+	p := m.UnitParameter(w)
+	area := m.watershed.AreaSubWatershed[w]
+	toDepth := state.Dt * 3.6 / area // 流量（m3/s）在一个时段内的水量折算为单元流域面积上的水深
+
+	// 线性水库 Q(t) = C·Q(t-1) + (1−C)·I(t) 的蓄量为 C/(1−C)·Q(t)
+	reservoir := func(daily, q float64) float64 {
+		c := math.Pow(daily, state.Dt/24)
+		if c >= 1 {
+			return 0
+		}
+		return c / (1 - c) * q * toDepth
+	}
+
+	pervious := 1 - p.IM
+	s := storage{
+		tension: pervious * (state.WU + state.WL + state.WD),
+		free:    pervious * state.S0 * state.FR,
+		routing: reservoir(p.CS, state.QS) + reservoir(p.CI, state.QI) + reservoir(p.CG, state.QG),
+	}
+	if area >= 200 {
+		s.routing += reservoir(p.CR, state.QU)
+	}
+	if m.network == nil {
+		s.channel = muskingumStorage(p.KE, p.XE, state.Dt, state.QU, state.O) * 3.6 / area
+	}
+	return s
+}
+
+// reachStorage 返回各河段的河道蓄量之和，m3/s·h
+func (m *Model) reachStorage() float64 {
+	total := 0.0
+	for i, reach := range m.reaches {
+		p := m.network.ReachParameter(m.network.Reaches[i], m.parameter)
+		total += muskingumStorage(p.KE, p.XE, reach.Dt, reach.QU, reach.O)
+	}
+	return total
+}
+
+// muskingumStorage 返回马斯京根河段的蓄量，m3/s·h
+// 各子河段蓄量 K·(x·I + (1−x)·O) 加上 ∆t·(I − O)/2，使以时段末流量计的入流、出流与蓄量变化相平衡
+func muskingumStorage(KE, XE, dt, inflow float64, O []float64) float64 {
+	n, err := Data.ReachCount(KE, dt)
+	if err != nil {
+		n = max(1, int(math.Round(KE/dt)))
+	}
+	xl := 0.5 - float64(n)*(1-2*XE)/2
+
+	total := 0.0
+	in := inflow
+	for i := 0; i < n; i++ {
+		out := 0.0
+		if i < len(O) {
+			out = O[i]
+		}
+		total += dt*(xl*in+(1-xl)*out) + dt*(in-out)/2
+		in = out
+	}
+	return total
+}
+
+// newBalance 创建nT个时段的水量平衡核算结果
+func (m *Model) newBalance(nT int) *Balance {
+	return &Balance{
+		Tolerance: m.balanceTol,
+		Steps:     make([]Budget, nT),
+		Units:     make([]Budget, len(m.states)),
+	}
+}
+
+// account 核算第t个时段的水量平衡，before为时段初各单元流域蓄量，reachBefore为时段初河段蓄量
+func (m *Model) account(b *Balance, t int, before []storage, reachBefore float64) {
+	totalArea := 0.0
+	for _, area := range m.watershed.AreaSubWatershed {
+		totalArea += area
+	}
+
+	step := &b.Steps[t]
+	for w, state := range m.states {
+		area := m.watershed.AreaSubWatershed[w]
+		toDepth := state.Dt * 3.6 / area
+		after := m.unitStorage(w, state)
+		pervious := 1 - m.UnitParameter(w).IM
+
+		u := Budget{
+			P:            state.P,
+			E:            pervious*state.E + (1-pervious)*state.P - state.RIM,
+			R:            pervious*state.R + state.RIM,
+			Runoff:       pervious*(state.RS+state.RI+state.RG) + state.RIM,
+			Inflow:       state.QU * toDepth,
+			Outflow:      state.O2 * toDepth,
+			DeltaTension: after.tension - before[w].tension,
+			DeltaFree:    after.free - before[w].free,
+			DeltaRouting: after.routing - before[w].routing,
+			DeltaChannel: after.channel - before[w].channel,
+		}
+		if m.network != nil {
+			u.Outflow = 0
+		}
+		u.Soil = u.P - u.E - u.R - u.DeltaTension
+		u.Free = u.R - u.Runoff - u.DeltaFree
+		u.Routing = u.Runoff - u.Inflow - u.DeltaRouting
+		if m.network == nil {
+			u.Channel = u.Inflow - u.Outflow - u.DeltaChannel
+		}
+
+		if r := u.Residual(); math.Abs(r) > m.balanceTol {
+			b.violate(Violation{Step: t, Unit: w, Residual: r})
+		}
+		b.Units[w].add(&u, 1)
+		step.add(&u, area/totalArea)
+	}
+
+	// 河网的河道蓄量及闭合误差只在全流域核算
+	if m.network != nil {
+		step.Outflow = m.states[0].Q * m.Dt() * 3.6 / totalArea
+		step.DeltaChannel = (m.reachStorage() - reachBefore) * 3.6 / totalArea
+		step.Channel = step.Inflow - step.Outflow - step.DeltaChannel
+		if math.Abs(step.Channel) > m.balanceTol {
+			b.violate(Violation{Step: t, Unit: -1, Residual: step.Channel})
+		}
+	}
+	b.Total.add(step, 1)
+}
+
+// violate 记录一处超过容许值的闭合误差
+func (b *Balance) violate(v Violation) {
+	b.NumViolations++
+	if len(b.Violations) < maxViolations {
+		b.Violations = append(b.Violations, v)
+	}
+}
@@ -0,0 +1,75 @@
+package Model
+
+import (
+	"demo2/Data"
+	"math"
+	"strings"
+	"testing"
+)
+
+// 默认计算流程及河网演算的各环节均守恒，各单元流域逐时段闭合误差应在容许值内
+func TestBalanceCloses(t *testing.T) {
+	const tolerance = 1e-6
+	for _, network := range []bool{false, true} {
+		m := exampleModel(t, 0, network)
+		m.SetBalance(tolerance)
+		result, err := m.Run(m.NumSteps())
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := result.Balance
+		if err := b.Err(); err != nil {
+			t.Errorf("河网演算%v: %v", network, err)
+		}
+		total := &b.Total
+		for name, residual := range map[string]float64{"产流": total.Soil, "分水源": total.Free, "汇流": total.Routing, "河道": total.Channel} {
+			if math.Abs(residual) > tolerance {
+				t.Errorf("河网演算%v时全流域%s误差为%g", network, name, residual)
+			}
+		}
+		// 全流域累计：P − E − 出流 − 各蓄量变化 = 0
+		storage := total.DeltaTension + total.DeltaFree + total.DeltaRouting + total.DeltaChannel
+		if r := total.P - total.E - total.Outflow - storage; math.Abs(r) > tolerance {
+			t.Errorf("河网演算%v时全流域总闭合误差为%g", network, r)
+		}
+		if total.Outflow <= 0 {
+			t.Errorf("河网演算%v时全流域水量平衡为%+v", network, *total)
+		}
+	}
+}
+
+// leak 丢弃地下径流的模块，用于检验水量不守恒时能否发现
+type leak struct{ state *Data.State }
+
+func (l *leak) SetParmameter(parameter *Data.Parameter) {}
+func (l *leak) SetState(state *Data.State)              { l.state = state }
+func (l *leak) Calculate()                              {}
+func (l *leak) UpdateState(state *Data.State)           { state.RG = 0 }
+func (l *leak) Destroy()                                {}
+
+func TestBalanceViolations(t *testing.T) {
+	m := exampleModel(t, 0, false)
+	if err := m.pipeline.InsertBefore(StageConfluence, "leak", &leak{}); err != nil {
+		t.Fatal(err)
+	}
+	m.SetBalance(1e-6)
+	result, err := m.Run(m.NumSteps())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := result.Balance
+	if b.NumViolations == 0 || len(b.Violations) != min(b.NumViolations, maxViolations) {
+		t.Fatalf("超限记录%d条，保存%d条", b.NumViolations, len(b.Violations))
+	}
+	if b.Total.Free <= 0 || math.Abs(b.Total.Soil) > 1e-6 {
+		t.Errorf("丢弃地下径流时应只有分水源误差，实际为%+v", b.Total)
+	}
+	if err := b.Err(); err == nil || !strings.Contains(err.Error(), "单元流域") {
+		t.Errorf("错误为%v", err)
+	}
+
+	m.SetBalance(-1)
+	if result, _ := m.Run(10); result.Balance != nil {
+		t.Error("容许值小于0时不应核算水量平衡")
+	}
+}
@@ -0,0 +1,526 @@
+package Model
+
+import (
+	"demo2/Data"
+	"demo2/Muskingum"
+	"demo2/Network"
+	"demo2/Watershed"
+	"fmt"
+	"math"
+)
+
+// Model 新安江模型模拟引擎
+// 按计算流程（默认为 蒸散发 → 产流 → 分水源 → 坡面汇流 → 河道汇流）逐时段逐单元流域计算
+type Model struct {
+	watershed *Watershed.Watershed // 流域信息及各单元流域逐时段降雨、蒸发
+	parameter *Data.Parameter      // 模型参数
+	units     []*Data.Parameter    // 各单元流域参数，为nil时各单元流域共用parameter
+
+	initial []*Data.State // 各单元流域初始状态
+	states  []*Data.State // 各单元流域当前状态
+
+	// ========预热期======== //
+	warmup    int     // 预热期时段数，预热期内照常计算，但不参与目标函数及评价
+	spinup    int     // 预热期最大重复计算次数，为0时不重复
+	spinupTol float64 // 预热期首末蓄量变化小于该值（mm）时认为已稳定
+
+	// ========模型模块======== //
+	pipeline *Pipeline // 计算流程，默认为 蒸散发 → 产流 → 分水源 → 坡面汇流 → 河道汇流
+
+	// ========河网======== //
+	network      *Network.Network      // 河网拓扑，为nil时各单元流域出流分别演算至流域出口后叠加
+	reaches      []*Data.State         // 各河段当前状态，QU0、QU为时段初、末入流，O2为出流
+	reachInitial []*Data.State         // 各河段初始状态，为nil时由单元流域初始出口流量确定
+	routing      []Muskingum.Muskingum // 各河段的河道汇流
+
+	// ========快照======== //
+	snapshotSteps map[int]bool // Run中保存快照的时段（已计算的时段数）
+
+	// ========水量平衡======== //
+	balanceTol float64 // 单元流域单时段闭合误差容许值，mm，小于0时不核算
+}
+
+// Result 模拟结果，单元流域过程按[单元流域][时段]存储
+type Result struct {
+	Warmup int // 预热期时段数，评价时应跳过前Warmup个时段
+	Spinup int // 预热期实际重复计算次数
+
+	Q  []float64   // 流域出口断面流量过程，m3/s
+	E  [][]float64 // 各单元流域蒸散发量过程，mm
+	R  [][]float64 // 各单元流域产流量过程，mm
+	W  [][]float64 // 各单元流域时段末张力水蓄量过程，mm
+	QU [][]float64 // 各单元流域出口流量过程，m3/s
+	O2 [][]float64 // 各单元流域在流域出口断面形成的出流过程，m3/s，河网演算时为0
+
+	Reach  [][]float64 // 河网演算时各河段出口流量过程，m3/s，按[河段][时段]存储
+	Gauges []string    // 河网演算时各控制断面名称
+	Gauge  [][]float64 // 河网演算时各控制断面流量过程，m3/s，按[断面][时段]存储
+
+	Snapshots []*Snapshot // 由SetSnapshotSteps指定时段的状态快照
+	Balance   *Balance    // 由SetBalance开启的水量平衡核算结果
+}
+
+// Evaluated 返回预热期之后的流域出口断面流量过程
+func (r *Result) Evaluated() []float64 {
+	return r.Q[min(r.Warmup, len(r.Q)):]
+}
+
+// NewModel 创建模拟引擎，watershed须已由Watershed.Calculate计算出各单元流域降雨、蒸发
+// 各单元流域初始状态默认取 WU=5, WL=20, WD=30, 时段长24h；流域信息或驱动数据无效时返回错误
+func NewModel(watershed *Watershed.Watershed, parameter *Data.Parameter) (*Model, error) {
+	if err := checkWatershed(watershed); err != nil {
+		return nil, err
+	}
+	if parameter == nil {
+		return nil, fmt.Errorf("未设置模型参数")
+	}
+	if err := parameter.Validate(); err != nil {
+		return nil, fmt.Errorf("模型参数不合理: %w", err)
+	}
+
+	m := &Model{
+		watershed:  watershed,
+		parameter:  parameter,
+		spinupTol:  0.1,
+		pipeline:   DefaultPipeline(),
+		balanceTol: -1,
+	}
+
+	nw := watershed.GetnW()
+	m.initial = make([]*Data.State, nw)
+	m.states = make([]*Data.State, nw)
+	for w := 0; w < nw; w++ {
+		m.initial[w] = &Data.State{
+			WU: 5,
+			WL: 20,
+			WD: 30,
+			W:  55,
+			Dt: 24.0,
+		}
+	}
+	m.Reset()
+
+	m.setParameter(parameter)
+
+	return m, nil
+}
+
+// checkWatershed 检查单元流域面积及各单元流域逐时段降雨、蒸发是否完整
+func checkWatershed(watershed *Watershed.Watershed) error {
+	nw := watershed.GetnW()
+	if nw <= 0 || len(watershed.AreaSubWatershed) != nw {
+		return fmt.Errorf("单元流域个数%d与面积个数%d不一致", nw, len(watershed.AreaSubWatershed))
+	}
+	if len(watershed.P) == 0 || len(watershed.P) != len(watershed.EM) {
+		return fmt.Errorf("单元流域降雨%d个时段、蒸发%d个时段，不能为空且应一致", len(watershed.P), len(watershed.EM))
+	}
+	for t := range watershed.P {
+		if len(watershed.P[t]) != nw || len(watershed.EM[t]) != nw {
+			return fmt.Errorf("第%d时段单元流域降雨或蒸发个数与单元流域个数%d不一致", t+1, nw)
+		}
+		for w := 0; w < nw; w++ {
+			if math.IsNaN(watershed.P[t][w]) || math.IsNaN(watershed.EM[t][w]) {
+				return fmt.Errorf("第%d时段第%d个单元流域降雨或蒸发缺测", t+1, w+1)
+			}
+		}
+	}
+	return nil
+}
+
+// NumSteps 返回驱动数据的时段数，Run的时段数不能超过该值
+func (m *Model) NumSteps() int {
+	return len(m.watershed.P)
+}
+
+// SetParameter 设置模型参数，设置了各单元流域参数时仅作为河网演算中未给出的KE、XE
+// 参数不合理时返回错误且不修改参数
+func (m *Model) SetParameter(parameter *Data.Parameter) error {
+	if err := parameter.Validate(); err != nil {
+		return fmt.Errorf("模型参数不合理: %w", err)
+	}
+	m.setParameter(parameter)
+	return nil
+}
+
+// setParameter 设置已检查过的模型参数
+func (m *Model) setParameter(parameter *Data.Parameter) {
+	m.parameter = parameter
+	m.setModuleParameter(parameter)
+
+	if m.network != nil {
+		for i, reach := range m.network.Reaches {
+			m.routing[i].SetParmameter(m.network.ReachParameter(reach, parameter))
+		}
+	}
+}
+
+// SetUnitParameters 设置各单元流域参数，可由Data.UnitParameters.Resolve得到；为nil时各单元流域共用模型参数
+func (m *Model) SetUnitParameters(parameters []*Data.Parameter) error {
+	if parameters == nil {
+		m.units = nil
+		m.setModuleParameter(m.parameter)
+		return nil
+	}
+	if len(parameters) != len(m.states) {
+		return fmt.Errorf("单元流域参数个数%d与单元流域个数%d不一致", len(parameters), len(m.states))
+	}
+	for w, p := range parameters {
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("第%d个单元流域参数不合理: %w", w+1, err)
+		}
+	}
+	if m.network == nil {
+		for w, p := range parameters {
+			if _, err := Data.ReachCount(p.KE, m.Dt()); err != nil {
+				return fmt.Errorf("第%d个单元流域: %w", w+1, err)
+			}
+		}
+	}
+
+	m.units = parameters
+	return nil
+}
+
+// UnitParameter 返回第w个单元流域的参数
+func (m *Model) UnitParameter(w int) *Data.Parameter {
+	if m.units != nil {
+		return m.units[w]
+	}
+	return m.parameter
+}
+
+// setModuleParameter 设置各模块的参数
+func (m *Model) setModuleParameter(parameter *Data.Parameter) {
+	m.pipeline.SetParmameter(parameter)
+}
+
+// SetPipeline 设置计算流程，用于替换、增删或调整模块；流程中的模块不能与其他模型共用
+func (m *Model) SetPipeline(pipeline *Pipeline) error {
+	if pipeline == nil || len(pipeline.stages) == 0 {
+		return fmt.Errorf("计算流程中没有模块")
+	}
+	m.pipeline = pipeline
+	m.setModuleParameter(m.parameter)
+	return nil
+}
+
+// Pipeline 返回计算流程
+func (m *Model) Pipeline() *Pipeline {
+	return m.pipeline
+}
+
+// SetNetwork 设置河网拓扑，各单元流域出流汇入所在河段，按上游到下游的顺序逐河段演算至流域出口
+// network为nil时恢复为各单元流域出流分别演算至流域出口后叠加
+func (m *Model) SetNetwork(network *Network.Network) error {
+	if network == nil {
+		m.network, m.reaches, m.reachInitial, m.routing = nil, nil, nil, nil
+		return nil
+	}
+	if err := network.Check(len(m.states), m.parameter, m.Dt()); err != nil {
+		return err
+	}
+
+	m.network = network
+	m.reaches = make([]*Data.State, len(network.Reaches))
+	m.reachInitial = nil
+	m.routing = make([]Muskingum.Muskingum, len(network.Reaches))
+	m.setParameter(m.parameter)
+	m.resetReaches()
+	return nil
+}
+
+// Network 返回河网拓扑，未设置时为nil
+func (m *Model) Network() *Network.Network {
+	return m.network
+}
+
+// SetInitialState 设置第w个单元流域的初始状态，并将当前状态及各河段状态重置为初始状态
+// 状态的时段长沿用模型当前的时段长
+func (m *Model) SetInitialState(w int, state *Data.State) {
+	dt := m.initial[w].Dt
+	m.initial[w] = copyState(state)
+	m.initial[w].Dt = dt
+	m.states[w] = copyState(m.initial[w])
+	m.resetReaches()
+}
+
+// SetInitialStates 设置各单元流域的初始状态，检查其与模型参数相容后应用
+func (m *Model) SetInitialStates(states []*Data.State) error {
+	if len(states) != len(m.initial) {
+		return fmt.Errorf("初始状态个数%d与单元流域个数%d不一致", len(states), len(m.initial))
+	}
+
+	for w, state := range states {
+		s := copyState(state)
+		s.Dt = m.initial[w].Dt
+		if err := s.CheckInitial(m.UnitParameter(w)); err != nil {
+			return fmt.Errorf("第%d个单元流域初始状态无效: %w", w+1, err)
+		}
+		m.SetInitialState(w, s)
+	}
+
+	return nil
+}
+
+// SetDt 设置模型计算时段长，h，须能整除24h，且KE须为时段长的正整数倍
+// 日模型参数（KI、KG、CS、CI、CG、CR）由各模块按 M = 24/∆t 换算为计算时段参数
+func (m *Model) SetDt(dt float64) error {
+	if err := Data.CheckDt(dt); err != nil {
+		return err
+	}
+	if m.network != nil {
+		if err := m.network.Check(len(m.states), m.parameter, dt); err != nil {
+			return err
+		}
+	} else {
+		for w := range m.states {
+			if _, err := Data.ReachCount(m.UnitParameter(w).KE, dt); err != nil {
+				return err
+			}
+		}
+	}
+
+	for w := range m.initial {
+		m.initial[w].Dt = dt
+		m.states[w].Dt = dt
+	}
+	m.resetReaches()
+	return nil
+}
+
+// Dt 返回模型计算时段长，h
+func (m *Model) Dt() float64 {
+	return m.initial[0].Dt
+}
+
+// SetWarmup 设置预热期时段数，预热期内照常计算但不参与评价
+func (m *Model) SetWarmup(warmup int) {
+	m.warmup = max(warmup, 0)
+}
+
+// SetSpinup 设置预热期最大重复计算次数及蓄量稳定阈值（mm）
+// 每次以上一次预热期末的状态作为初始状态重新计算预热期，直到各单元流域
+// 预热期首末张力水蓄量与自由水蓄量的变化均小于tol，或达到最大次数
+func (m *Model) SetSpinup(cycles int, tol float64) {
+	m.spinup = max(cycles, 0)
+	if tol > 0 {
+		m.spinupTol = tol
+	}
+}
+
+// States 返回各单元流域当前状态
+func (m *Model) States() []*Data.State {
+	return m.states
+}
+
+// Reaches 返回河网演算时各河段当前状态，未设置河网时为nil
+func (m *Model) Reaches() []*Data.State {
+	return m.reaches
+}
+
+// Reset 将各单元流域当前状态重置为初始状态
+func (m *Model) Reset() {
+	m.resetTo(m.initial)
+}
+
+// resetTo 将各单元流域当前状态重置为给定状态
+func (m *Model) resetTo(states []*Data.State) {
+	for w := range states {
+		m.states[w] = copyState(states[w])
+	}
+	m.resetReaches()
+}
+
+// resetReaches 将各河段状态重置为初始状态，未由快照给出时时段初入流取汇入该河段的单元流域出口流量之和
+func (m *Model) resetReaches() {
+	if m.network == nil {
+		return
+	}
+	if m.reachInitial != nil {
+		for i, reach := range m.reachInitial {
+			m.reaches[i] = copyState(reach)
+		}
+		return
+	}
+	for i := range m.reaches {
+		m.reaches[i] = &Data.State{Dt: m.Dt()}
+	}
+	for w, id := range m.network.UnitReach {
+		m.reaches[m.network.Index(id)].QU += m.states[w].QU
+	}
+}
+
+// spinUp 重复计算预热期直到蓄量稳定，返回稳定后的初始状态及重复计算次数
+func (m *Model) spinUp() ([]*Data.State, int) {
+	start := m.initial
+	if m.warmup == 0 || m.spinup == 0 {
+		return start, 0
+	}
+
+	cycle := 0
+	for cycle < m.spinup {
+		cycle++
+		m.resetTo(start)
+		for t := 0; t < m.warmup; t++ {
+			m.Step(t)
+		}
+
+		change := 0.0
+		end := make([]*Data.State, len(m.states))
+		for w, state := range m.states {
+			change = max(change,
+				math.Abs(state.W-start[w].W),
+				math.Abs(state.S0*state.FR-start[w].S0*start[w].FR))
+			end[w] = copyState(state)
+		}
+		start = end
+
+		if change < m.spinupTol {
+			break
+		}
+	}
+
+	return start, cycle
+}
+
+// Step 从当前状态计算第t个时段，返回流域出口断面流量，m3/s
+func (m *Model) Step(t int) float64 {
+	Q := 0.0
+	for w, state := range m.states {
+		if m.units != nil {
+			m.setModuleParameter(m.units[w])
+		}
+		state.SetInput(t, w, m.watershed)
+		if m.network != nil {
+			m.pipeline.Calculate(state, StageRouting)
+			state.O2 = 0
+			continue
+		}
+		m.pipeline.Calculate(state, "")
+		Q += state.O2
+	}
+	if m.network != nil {
+		Q = m.route()
+	}
+	m.states[0].Q = Q
+
+	return Q
+}
+
+// route 将各单元流域出流汇入所在河段，按上游到下游的顺序逐河段演算，返回流域出口断面流量，m3/s
+func (m *Model) route() float64 {
+	inflow := make([]float64, len(m.reaches))
+	for w, id := range m.network.UnitReach {
+		inflow[m.network.Index(id)] += m.states[w].QU
+	}
+
+	Q := 0.0
+	for _, i := range m.network.Order() {
+		reach := m.reaches[i]
+		reach.QU0 = reach.QU
+		reach.QU = inflow[i]
+		m.routing[i].SetState(reach)
+		m.routing[i].Calculate()
+		m.routing[i].UpdateState(reach)
+		if d := m.network.Reaches[i].Downstream; d != 0 {
+			inflow[m.network.Index(d)] += reach.O2
+		} else {
+			Q = reach.O2
+		}
+	}
+	return Q
+}
+
+// Run 从初始状态计算nT个时段，返回各单元流域及流域出口断面的过程
+// 设置了预热期重复计算时，先由spinUp得到稳定的初始状态
+// nT超出驱动数据的时段数，或预热期不短于nT（预热期之后没有可评价的时段）时返回错误
+func (m *Model) Run(nT int) (*Result, error) {
+	if nT < 0 || nT > m.NumSteps() {
+		return nil, fmt.Errorf("计算时段数%d超出驱动数据的时段数%d", nT, m.NumSteps())
+	}
+	if m.warmup > 0 && m.warmup >= nT {
+		return nil, fmt.Errorf("预热期时段数%d不小于计算时段数%d", m.warmup, nT)
+	}
+	start, cycles := m.spinUp()
+	m.resetTo(start)
+
+	nw := len(m.states)
+	result := &Result{
+		Warmup: m.warmup,
+		Spinup: cycles,
+		Q:      make([]float64, nT),
+		E:      newSeries(nw, nT),
+		R:      newSeries(nw, nT),
+		W:      newSeries(nw, nT),
+		QU:     newSeries(nw, nT),
+		O2:     newSeries(nw, nT),
+	}
+	if m.network != nil {
+		result.Reach = newSeries(len(m.reaches), nT)
+		result.Gauge = newSeries(len(m.network.Gauges), nT)
+		for _, gauge := range m.network.Gauges {
+			result.Gauges = append(result.Gauges, gauge.Name)
+		}
+	}
+
+	if m.balanceTol >= 0 {
+		result.Balance = m.newBalance(nT)
+	}
+
+	for t := 0; t < nT; t++ {
+		var before []storage
+		reachBefore := 0.0
+		if result.Balance != nil {
+			before = make([]storage, nw)
+			for w, state := range m.states {
+				before[w] = m.unitStorage(w, state)
+			}
+			if m.network != nil {
+				reachBefore = m.reachStorage()
+			}
+		}
+
+		result.Q[t] = m.Step(t)
+		if result.Balance != nil {
+			m.account(result.Balance, t, before, reachBefore)
+		}
+		for w, state := range m.states {
+			result.E[w][t] = state.E
+			result.R[w][t] = state.R
+			result.W[w][t] = state.W
+			result.QU[w][t] = state.QU
+			result.O2[w][t] = state.O2
+		}
+		if m.network != nil {
+			for i, reach := range m.reaches {
+				result.Reach[i][t] = reach.O2
+			}
+			for g, gauge := range m.network.Gauges {
+				result.Gauge[g][t] = m.reaches[m.network.Index(gauge.Reach)].O2
+			}
+		}
+		if m.snapshotSteps[t+1] {
+			result.Snapshots = append(result.Snapshots, m.Snapshot(t+1))
+		}
+	}
+
+	return result, nil
+}
+
+// newSeries 创建[单元流域][时段]的二维数组
+func newSeries(nw, nT int) [][]float64 {
+	series := make([][]float64, nw)
+	for w := range series {
+		series[w] = make([]float64, nT)
+	}
+	return series
+}
+
+// copyState 深拷贝状态，包括各子河段出流
+func copyState(state *Data.State) *Data.State {
+	s := *state
+	if state.O != nil {
+		s.O = append([]float64(nil), state.O...)
+	}
+	return &s
+}
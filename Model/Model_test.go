@@ -0,0 +1,143 @@
+package Model
+
+import (
+	"demo2/Data"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 预热期重复计算至蓄量稳定，或达到最大次数
+func TestSpinUp(t *testing.T) {
+	m := exampleModel(t, 0, false)
+	// 预热期较短时初始状态的影响要经多次重复计算才消失
+	m.SetWarmup(10)
+	m.SetSpinup(50, 0.1)
+	result, err := m.Run(m.NumSteps())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Spinup < 2 || result.Spinup >= 50 {
+		t.Fatalf("预热期重复计算%d次，应在达到最大次数之前稳定", result.Spinup)
+	}
+	// 稳定后的初始状态再计算一次预热期，蓄量变化应小于阈值
+	start, _ := m.spinUp()
+	m.resetTo(start)
+	for step := 0; step < 10; step++ {
+		m.Step(step)
+	}
+	for w, state := range m.states {
+		if d := state.W - start[w].W; d > 0.1 || d < -0.1 {
+			t.Errorf("第%d个单元流域预热期首末张力水蓄量变化%gmm", w+1, d)
+		}
+	}
+
+	m.SetSpinup(3, 1e-12)
+	if result, err := m.Run(m.NumSteps()); err != nil || result.Spinup != 3 {
+		t.Errorf("未稳定时应在重复计算3次后停止，实际为%d次、%v", result.Spinup, err)
+	}
+
+	m.SetWarmup(0)
+	if result, err := m.Run(m.NumSteps()); err != nil || result.Spinup != 0 {
+		t.Errorf("没有预热期时不应重复计算，实际为%d次、%v", result.Spinup, err)
+	}
+}
+
+func TestWarmup(t *testing.T) {
+	m := exampleModel(t, 0, false)
+	base, err := m.Run(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.SetWarmup(30)
+	result, err := m.Run(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 预热期照常计算，只是不参与评价
+	if result.Warmup != 30 || len(result.Evaluated()) != 70 || result.Evaluated()[0] != base.Q[30] {
+		t.Errorf("预热期%d个时段，评价%d个时段", result.Warmup, len(result.Evaluated()))
+	}
+
+	m.SetWarmup(-5)
+	if result, err := m.Run(100); err != nil || result.Warmup != 0 {
+		t.Errorf("负的预热期应视为0，实际为%d、%v", result.Warmup, err)
+	}
+
+	for _, warmup := range []int{100, 200} {
+		m.SetWarmup(warmup)
+		if _, err := m.Run(100); err == nil || !strings.Contains(err.Error(), "不小于计算时段数100") {
+			t.Errorf("预热期%d个时段时错误为%v", warmup, err)
+		}
+	}
+}
+
+func TestRunSteps(t *testing.T) {
+	m := exampleModel(t, 0, false)
+	for _, nT := range []int{-1, m.NumSteps() + 1} {
+		if _, err := m.Run(nT); err == nil || !strings.Contains(err.Error(), "超出驱动数据的时段数") {
+			t.Errorf("计算%d个时段时错误为%v", nT, err)
+		}
+	}
+
+	// 计算部分时段的结果应与全部时段的前nT个时段相同，且每次Run从初始状态开始
+	full, err := m.Run(m.NumSteps())
+	if err != nil {
+		t.Fatal(err)
+	}
+	part, err := m.Run(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(part.Q) != 100 || len(part.W[0]) != 100 || !reflect.DeepEqual(part.Q, full.Q[:100]) {
+		t.Error("计算前100个时段的流量过程与全部时段的前100个时段不一致")
+	}
+	if empty, err := m.Run(0); err != nil || len(empty.Q) != 0 {
+		t.Errorf("计算0个时段时得到%v、%v", empty, err)
+	}
+}
+
+// 时段长须整除24h，且各单元流域的KE须为时段长的正整数倍，不满足时不修改时段长
+func TestSetDt(t *testing.T) {
+	m := exampleModel(t, 0, false)
+	if err := m.SetDt(12); err != nil {
+		t.Fatal(err)
+	}
+	// 第1个单元流域KE为12h，其余为流域参数的24h
+	units := make([]*Data.Parameter, len(m.States()))
+	for w := range units {
+		p := *m.UnitParameter(w)
+		units[w] = &p
+	}
+	units[0].KE = 12
+	if err := m.SetUnitParameters(units); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		dt  float64
+		err string // 为空时应无错误
+	}{
+		{5, "时段长5h不能整除24h"},
+		{0, "时段长0h应在(0, 24]内"},
+		{8, "KE=12h不是时段长8h的正整数倍"},   // 12/8不是整数
+		{24, "KE=12h不是时段长24h的正整数倍"}, // 子河段数小于1
+		{6, ""},
+	}
+	for _, tt := range tests {
+		before := m.Dt()
+		err := m.SetDt(tt.dt)
+		if tt.err == "" {
+			if err != nil || m.Dt() != tt.dt {
+				t.Errorf("SetDt(%g)的错误为%v，时段长为%g", tt.dt, err, m.Dt())
+			}
+			continue
+		}
+		if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+			t.Errorf("SetDt(%g)的错误为%v，应以%q开头", tt.dt, err, tt.err)
+		}
+		if m.Dt() != before {
+			t.Errorf("SetDt(%g)失败后时段长由%g变为%g", tt.dt, before, m.Dt())
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package Model
+
+import (
+	"demo2/Confluence"
+	"demo2/Data"
+	"demo2/Evapotranspiration"
+	"demo2/Muskingum"
+	"demo2/Source"
+	Runoff "demo2/runoff"
+	"fmt"
+)
+
+// Component 模型模块，每个时段对每个单元流域依次调用 SetState → Calculate → UpdateState
+// 模块之间只通过Data.State传递变量，因此可以替换或调整顺序
+type Component interface {
+	SetParmameter(parameter *Data.Parameter) // 设置模型参数
+	SetState(state *Data.State)              // 从单元流域状态读取本时段输入
+	Calculate()                              // 计算一个时段
+	UpdateState(state *Data.State)           // 将计算结果写回单元流域状态
+	Destroy()                                // 释放模块内部数据
+}
+
+// 默认流程中各模块的名称
+const (
+	StageEvapotranspiration = "evapotranspiration" // 流域蒸散发
+	StageRunoff             = "runoff"             // 流域产流
+	StageSource             = "source"             // 流域分水源
+	StageConfluence         = "confluence"         // 单元流域汇流
+	StageRouting            = "routing"            // 河道汇流，设置河网时不计算，由河网演算代替
+)
+
+// stage 流程中的一个模块
+type stage struct {
+	name      string
+	component Component
+}
+
+// Pipeline 模型计算流程，按添加顺序逐个调用各模块
+// 最后一个模块须给出State.O2，即单元流域在流域出口断面形成的出流
+type Pipeline struct {
+	stages []stage
+}
+
+// NewPipeline 创建空的计算流程
+func NewPipeline() *Pipeline {
+	return &Pipeline{}
+}
+
+// DefaultPipeline 创建新安江模型的默认流程：蒸散发 → 产流 → 分水源 → 坡面汇流 → 河道汇流
+func DefaultPipeline() *Pipeline {
+	return NewPipeline().
+		Add(StageEvapotranspiration, &Evapotranspiration.Evapotranspiration{}).
+		Add(StageRunoff, &Runoff.Runoff{}).
+		Add(StageSource, &Source.Source{N: 1}).
+		Add(StageConfluence, &Confluence.Confluence{}).
+		Add(StageRouting, &Muskingum.Muskingum{N: 1})
+}
+
+// Add 在流程末尾添加模块，名称重复时替换原模块
+func (p *Pipeline) Add(name string, component Component) *Pipeline {
+	if i := p.index(name); i >= 0 {
+		p.stages[i].component = component
+		return p
+	}
+	p.stages = append(p.stages, stage{name, component})
+	return p
+}
+
+// Replace 替换指定名称的模块，如以其他蒸散发或河道汇流方法代替默认模块
+func (p *Pipeline) Replace(name string, component Component) error {
+	i := p.index(name)
+	if i < 0 {
+		return fmt.Errorf("流程中没有模块%s", name)
+	}
+	p.stages[i].component = component
+	return nil
+}
+
+// InsertBefore 在指定名称的模块之前插入模块
+func (p *Pipeline) InsertBefore(before, name string, component Component) error {
+	i := p.index(before)
+	if i < 0 {
+		return fmt.Errorf("流程中没有模块%s", before)
+	}
+	if p.index(name) >= 0 {
+		return fmt.Errorf("流程中已有模块%s", name)
+	}
+	p.stages = append(p.stages[:i], append([]stage{{name, component}}, p.stages[i:]...)...)
+	return nil
+}
+
+// Remove 移除指定名称的模块
+func (p *Pipeline) Remove(name string) error {
+	i := p.index(name)
+	if i < 0 {
+		return fmt.Errorf("流程中没有模块%s", name)
+	}
+	p.stages = append(p.stages[:i], p.stages[i+1:]...)
+	return nil
+}
+
+// Reorder 按给定的名称顺序重新排列模块，names须包含流程中的全部模块
+func (p *Pipeline) Reorder(names ...string) error {
+	if len(names) != len(p.stages) {
+		return fmt.Errorf("给出%d个模块名称，流程中有%d个模块", len(names), len(p.stages))
+	}
+	stages := make([]stage, 0, len(names))
+	for _, name := range names {
+		i := p.index(name)
+		if i < 0 {
+			return fmt.Errorf("流程中没有模块%s", name)
+		}
+		stages = append(stages, p.stages[i])
+	}
+	for i := range stages {
+		for j := i + 1; j < len(stages); j++ {
+			if stages[i].name == stages[j].name {
+				return fmt.Errorf("模块%s重复", stages[i].name)
+			}
+		}
+	}
+	p.stages = stages
+	return nil
+}
+
+// Names 返回按计算顺序排列的模块名称
+func (p *Pipeline) Names() []string {
+	names := make([]string, len(p.stages))
+	for i, s := range p.stages {
+		names[i] = s.name
+	}
+	return names
+}
+
+// Component 返回指定名称的模块，不存在时返回nil
+func (p *Pipeline) Component(name string) Component {
+	if i := p.index(name); i >= 0 {
+		return p.stages[i].component
+	}
+	return nil
+}
+
+// SetParmameter 设置各模块的参数
+func (p *Pipeline) SetParmameter(parameter *Data.Parameter) {
+	for _, s := range p.stages {
+		s.component.SetParmameter(parameter)
+	}
+}
+
+// Calculate 对一个单元流域依次计算各模块，skip中的模块不计算
+func (p *Pipeline) Calculate(state *Data.State, skip string) {
+	for _, s := range p.stages {
+		if s.name == skip {
+			continue
+		}
+		s.component.SetState(state)
+		s.component.Calculate()
+		s.component.UpdateState(state)
+	}
+}
+
+// Destroy 释放各模块内部数据
+func (p *Pipeline) Destroy() {
+	for _, s := range p.stages {
+		s.component.Destroy()
+	}
+}
+
+// index 返回指定名称的模块下标，不存在时返回-1
+func (p *Pipeline) index(name string) int {
+	for i, s := range p.stages {
+		if s.name == name {
+			return i
+		}
+	}
+	return -1
+}
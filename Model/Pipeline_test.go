@@ -0,0 +1,164 @@
+package Model
+
+import (
+	"demo2/Confluence"
+	"demo2/Data"
+	"demo2/Evapotranspiration"
+	"demo2/Muskingum"
+	"demo2/Source"
+	Runoff "demo2/runoff"
+	"reflect"
+	"testing"
+)
+
+// counter 记录计算次数的模块
+type counter struct{ n int }
+
+func (c *counter) SetParmameter(parameter *Data.Parameter) {}
+func (c *counter) SetState(state *Data.State)              {}
+func (c *counter) Calculate()                              { c.n++ }
+func (c *counter) UpdateState(state *Data.State)           {}
+func (c *counter) Destroy()                                {}
+
+// scale 将本时段降雨放大factor倍的模块
+type scale struct{ factor float64 }
+
+func (s *scale) SetParmameter(parameter *Data.Parameter) {}
+func (s *scale) SetState(state *Data.State)              {}
+func (s *scale) Calculate()                              {}
+func (s *scale) UpdateState(state *Data.State)           { state.P *= s.factor }
+func (s *scale) Destroy()                                {}
+
+var defaultNames = []string{StageEvapotranspiration, StageRunoff, StageSource, StageConfluence, StageRouting}
+
+func TestPipelineEdit(t *testing.T) {
+	p := DefaultPipeline()
+	if !reflect.DeepEqual(p.Names(), defaultNames) {
+		t.Fatalf("默认流程为%v", p.Names())
+	}
+
+	c := &counter{}
+	p.Add(StageRunoff, c)
+	if p.Component(StageRunoff) != c || len(p.Names()) != 5 {
+		t.Error("Add名称重复时应替换原模块")
+	}
+	if err := p.Replace(StageRunoff, &Runoff.Runoff{}); err != nil || p.Component(StageRunoff) == c {
+		t.Errorf("Replace: %v", err)
+	}
+	if err := p.InsertBefore(StageRunoff, "scale", &scale{2}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{StageEvapotranspiration, "scale", StageRunoff, StageSource, StageConfluence, StageRouting}
+	if !reflect.DeepEqual(p.Names(), want) {
+		t.Errorf("插入后的流程为%v，应为%v", p.Names(), want)
+	}
+	if err := p.Remove("scale"); err != nil || !reflect.DeepEqual(p.Names(), defaultNames) {
+		t.Errorf("移除后的流程为%v，错误为%v", p.Names(), err)
+	}
+
+	errs := map[string]error{
+		"替换不存在的模块":   p.Replace("snow", c),
+		"移除不存在的模块":   p.Remove("snow"),
+		"在不存在的模块前插入": p.InsertBefore("snow", "scale", c),
+		"插入同名模块":     p.InsertBefore(StageRunoff, StageSource, c),
+		"重排时名称个数不符":  p.Reorder(StageRunoff, StageSource),
+		"重排时名称不存在":   p.Reorder(StageEvapotranspiration, StageRunoff, StageSource, StageConfluence, "snow"),
+		"重排时名称重复":    p.Reorder(StageEvapotranspiration, StageRunoff, StageRunoff, StageConfluence, StageRouting),
+	}
+	for name, err := range errs {
+		if err == nil {
+			t.Errorf("%s应返回错误", name)
+		}
+	}
+	if p.Component("snow") != nil || !reflect.DeepEqual(p.Names(), defaultNames) {
+		t.Errorf("操作失败时不应修改流程，实际为%v", p.Names())
+	}
+
+	if err := p.Reorder(StageRunoff, StageEvapotranspiration, StageSource, StageConfluence, StageRouting); err != nil {
+		t.Fatal(err)
+	}
+	if names := p.Names(); names[0] != StageRunoff || names[1] != StageEvapotranspiration {
+		t.Errorf("重排后的流程为%v", names)
+	}
+}
+
+func TestPipelineCalculateSkip(t *testing.T) {
+	a, b := &counter{}, &counter{}
+	p := NewPipeline().Add("a", a).Add("b", b)
+	p.Calculate(&Data.State{}, "b")
+	p.Calculate(&Data.State{}, "")
+	if a.n != 2 || b.n != 1 {
+		t.Errorf("模块a计算%d次、b计算%d次，应为2次、1次", a.n, b.n)
+	}
+}
+
+// 插入的自定义模块应参与计算并改变模拟结果
+func TestCustomComponent(t *testing.T) {
+	m := exampleModel(t, 0, false)
+	base, err := m.Run(m.NumSteps())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m = exampleModel(t, 0, false)
+	if err := m.Pipeline().InsertBefore(StageEvapotranspiration, "scale", &scale{2}); err != nil {
+		t.Fatal(err)
+	}
+	scaled, err := m.Run(m.NumSteps())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := func(q []float64) float64 {
+		total := 0.0
+		for _, v := range q {
+			total += v
+		}
+		return total
+	}
+	if sum(scaled.Q) <= 1.5*sum(base.Q) {
+		t.Errorf("降雨加倍后总径流%g，未加倍时为%g", sum(scaled.Q), sum(base.Q))
+	}
+
+	if err := m.SetPipeline(NewPipeline()); err == nil {
+		t.Error("计算流程中没有模块时应返回错误")
+	}
+}
+
+// 默认流程应与改为可配置流程之前依次调用各模块的计算完全相同
+func TestDefaultPipelineMatchesFixedSequence(t *testing.T) {
+	m := exampleModel(t, 0, false)
+	result, err := m.Run(m.NumSteps())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evapotranspiration := &Evapotranspiration.Evapotranspiration{}
+	runoff := &Runoff.Runoff{}
+	source := &Source.Source{N: 1}
+	confluence := &Confluence.Confluence{}
+	muskingum := &Muskingum.Muskingum{N: 1}
+	modules := []Component{evapotranspiration, runoff, source, confluence, muskingum}
+	for _, module := range modules {
+		module.SetParmameter(m.parameter)
+	}
+
+	states := make([]*Data.State, len(m.initial))
+	for w := range states {
+		states[w] = copyState(m.initial[w])
+	}
+	for step := 0; step < m.NumSteps(); step++ {
+		Q := 0.0
+		for w, state := range states {
+			state.SetInput(step, w, m.watershed)
+			for _, module := range modules {
+				module.SetState(state)
+				module.Calculate()
+				module.UpdateState(state)
+			}
+			Q += state.O2
+		}
+		if Q != result.Q[step] {
+			t.Fatalf("第%d时段流量为%g，依次调用各模块时为%g", step+1, result.Q[step], Q)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package Model
+
+import (
+	"demo2/Data"
+	"demo2/Network"
+	"demo2/Watershed"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// exampleModel 由IOexamples中的流域、驱动数据及模型参数创建模拟引擎，from之前的时段不参与计算
+func exampleModel(t *testing.T, from int, network bool) *Model {
+	t.Helper()
+	path := "../IOexamples/"
+	watershed := &Watershed.Watershed{}
+	io := &Watershed.IO{}
+	parameter := &Data.Parameter{}
+	if err := watershed.ReadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := io.ReadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := watershed.Calculate(io); err != nil {
+		t.Fatal(err)
+	}
+	if err := parameter.ReadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	watershed.P, watershed.EM = watershed.P[from:], watershed.EM[from:]
+
+	m, err := NewModel(watershed, parameter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if network {
+		n := &Network.Network{}
+		if err := n.ReadFile(path + "network示例.txt"); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.SetNetwork(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m
+}
+
+// 在第k时段末保存快照，经文件读回后热启动计算其余时段，流量过程应与不中断的计算完全相同
+func TestSnapshotRestore(t *testing.T) {
+	const k = 1000
+	for _, network := range []bool{false, true} {
+		full := exampleModel(t, 0, network)
+		full.SetSnapshotSteps([]int{k})
+		want, err := full.Run(full.NumSteps())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(want.Snapshots) != 1 || want.Snapshots[0].Step != k {
+			t.Fatalf("快照为%v，应只有第%d时段末的快照", want.Snapshots, k)
+		}
+
+		fileName := filepath.Join(t.TempDir(), "snapshot.json")
+		if err := want.Snapshots[0].WriteFile(fileName); err != nil {
+			t.Fatal(err)
+		}
+		snapshot, err := ReadSnapshot(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(snapshot, want.Snapshots[0]) {
+			t.Errorf("读回的快照与保存的快照不一致")
+		}
+
+		resumed := exampleModel(t, k, network)
+		if err := resumed.Restore(snapshot); err != nil {
+			t.Fatal(err)
+		}
+		got, err := resumed.Run(resumed.NumSteps())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got.Q, want.Q[k:]) {
+			t.Errorf("河网演算%v时热启动后的出口流量与不中断的计算不一致", network)
+		}
+		for i := range got.Reach {
+			if !reflect.DeepEqual(got.Reach[i], want.Reach[i][k:]) {
+				t.Errorf("热启动后第%d个河段的出流与不中断的计算不一致", i+1)
+			}
+		}
+		for w := range got.W {
+			if !reflect.DeepEqual(got.W[w], want.W[w][k:]) {
+				t.Errorf("热启动后第%d个单元流域的张力水蓄量与不中断的计算不一致", w+1)
+			}
+		}
+	}
+}
+
+func TestRestoreErrors(t *testing.T) {
+	m := exampleModel(t, 0, false)
+	tests := []struct {
+		name   string
+		modify func(s *Snapshot)
+	}{
+		{"格式版本不一致", func(s *Snapshot) { s.Version = SnapshotVersion + 1 }},
+		{"单元流域个数不一致", func(s *Snapshot) { s.States = s.States[1:] }},
+		{"河段个数不一致", func(s *Snapshot) { s.Reaches = []*Data.State{{Dt: s.Dt}} }},
+		{"时段长不一致", func(s *Snapshot) { s.Dt = 12 }},
+		{"状态与参数不相容", func(s *Snapshot) { s.States[0].WU = -1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshot := m.Snapshot(0)
+			tt.modify(snapshot)
+			if err := m.Restore(snapshot); err == nil {
+				t.Error("快照与模型不一致时应返回错误")
+			}
+		})
+	}
+	if _, err := ReadSnapshot(filepath.Join(t.TempDir(), "snapshot.json")); err == nil {
+		t.Error("文件不存在时应返回错误")
+	}
+}
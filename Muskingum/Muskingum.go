@@ -1,6 +1,9 @@
 package Muskingum
 
-import "demo2/Data"
+import (
+	"demo2/Data"
+	"math"
+)
 
 type Muskingum struct {
 	// ========模型参数======== //
@@ -40,8 +43,12 @@ func (m *Muskingum) UpdateState(state *Data.State) {
 }
 
 func (m *Muskingum) Calculate() {
-	m.KL = m.Dt                            // 为了保证马斯京根法的两个线性条件，每个单元河取 KL = ∆t
-	m.N = int(m.KE / m.KL)                 // 单元河段数
+	m.KL = m.Dt // 为了保证马斯京根法的两个线性条件，每个单元河取 KL = ∆t
+	n, err := Data.ReachCount(m.KE, m.KL)
+	if err != nil {
+		n = max(1, int(math.Round(m.KE/m.KL))) // KE不是∆t的整数倍时取最接近的河段数，模型运行前应已检查
+	}
+	m.N = n                                // 单元河段数
 	m.XL = 0.5 - float64(m.N)*(1-2*m.XE)/2 // 计算单元河段XL
 
 	denominator := 0.5*m.Dt + m.KL - m.KL*m.XL
@@ -49,7 +56,7 @@ func (m *Muskingum) Calculate() {
 	m.C1 = (0.5*m.Dt + m.KL*m.XL) / denominator
 	m.C2 = (-0.5*m.Dt + m.KL - m.KL*m.XL) / denominator
 
-	if m.O == nil {
+	if len(m.O) != m.N {
 		m.O = make([]float64, m.N) // 创建存储单元流域在子河段出口断面的出流量的动态数组
 		for n := 0; n < m.N; n++ {
 			m.O[n] = 0.0 // 单元流域在子河段出口断面的出流量为0
@@ -0,0 +1,305 @@
+package Network
+
+import (
+	"bufio"
+	"demo2/Data"
+	"demo2/Watershed"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// 河网文件名
+const NetworkFile = "network.txt"
+
+// Reach 河段，出口为下游河段的入口
+type Reach struct {
+	ID         int     // 河段编号
+	Downstream int     // 下游河段编号，0表示流域出口
+	KE         float64 // 马斯京根法演算参数（h），NaN表示取模型参数KE
+	XE         float64 // 马斯京根法演算参数，NaN表示取模型参数XE
+}
+
+// Gauge 控制断面，位于河段出口
+type Gauge struct {
+	Name  string // 断面名称
+	Reach int    // 所在河段编号
+}
+
+// Network 河网拓扑：各单元流域汇入的河段、河段上下游关系及控制断面
+type Network struct {
+	Reaches   []Reach // 河段
+	UnitReach []int   // 各单元流域出流汇入的河段编号
+	Gauges    []Gauge // 控制断面
+
+	index map[int]int // 河段编号到Reaches下标
+	order []int       // 按上游到下游排列的河段下标
+}
+
+// ReadFromFile 读取network.txt，每行“//”之后为注释，格式为：
+//
+//	河段数
+//	河段编号 下游河段编号(0为流域出口) KE XE    每个河段一行，KE、XE为“-”时取模型参数
+//	各单元流域汇入的河段编号                    按单元流域顺序一行
+//	控制断面数                                  可选
+//	断面名称 所在河段编号                       每个断面一行，断面位于河段出口
+func (n *Network) ReadFromFile(strPath string) error {
+	return n.ReadFile(strPath + NetworkFile)
+}
+
+// ReadFile 读取指定的河网文件，格式同network.txt
+func (n *Network) ReadFile(fileName string) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	type row struct {
+		line   int
+		fields []string
+	}
+	var rows []row
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if i := strings.Index(text, "//"); i >= 0 {
+			text = text[:i]
+		}
+		if fields := strings.Fields(text); len(fields) > 0 {
+			rows = append(rows, row{line, fields})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return &Watershed.ParseError{File: fileName, Err: err}
+	}
+
+	next := 0
+	nextRow := func() (row, error) {
+		if next >= len(rows) {
+			return row{}, &Watershed.ParseError{File: fileName, Line: line, Err: fmt.Errorf("文件行数不足")}
+		}
+		next++
+		return rows[next-1], nil
+	}
+	atoi := func(r row, i int) (int, error) {
+		v, err := strconv.Atoi(r.fields[i])
+		if err != nil {
+			return 0, &Watershed.ParseError{File: fileName, Line: r.line, Column: i + 1, Err: err}
+		}
+		return v, nil
+	}
+	param := func(r row, i int) (float64, error) {
+		if r.fields[i] == "-" {
+			return math.NaN(), nil
+		}
+		v, err := strconv.ParseFloat(r.fields[i], 64)
+		if err != nil {
+			return 0, &Watershed.ParseError{File: fileName, Line: r.line, Column: i + 1, Err: err}
+		}
+		return v, nil
+	}
+
+	// 读取河段
+	r, err := nextRow()
+	if err != nil {
+		return err
+	}
+	nr, err := atoi(r, 0)
+	if err != nil {
+		return err
+	}
+	if nr <= 0 {
+		return &Watershed.ParseError{File: fileName, Line: r.line, Column: 1, Err: fmt.Errorf("河段个数%d应为正数", nr)}
+	}
+	n.Reaches = make([]Reach, nr)
+	for i := 0; i < nr; i++ {
+		if r, err = nextRow(); err != nil {
+			return err
+		}
+		if len(r.fields) != 4 {
+			return &Watershed.ParseError{File: fileName, Line: r.line, Err: fmt.Errorf("河段应有4列，实际为%d列", len(r.fields))}
+		}
+		reach := &n.Reaches[i]
+		if reach.ID, err = atoi(r, 0); err != nil {
+			return err
+		}
+		if reach.Downstream, err = atoi(r, 1); err != nil {
+			return err
+		}
+		if reach.KE, err = param(r, 2); err != nil {
+			return err
+		}
+		if reach.XE, err = param(r, 3); err != nil {
+			return err
+		}
+	}
+
+	// 读取各单元流域汇入的河段
+	if r, err = nextRow(); err != nil {
+		return err
+	}
+	// 多出的河段行会被当作单元流域汇入的河段行，其后的行又被当作控制断面，须逐一排除
+	tooMany := fmt.Errorf("河段行数多于声明的河段个数%d", nr)
+	n.UnitReach = make([]int, len(r.fields))
+	for i := range r.fields {
+		if n.UnitReach[i], err = atoi(r, i); err != nil {
+			if len(r.fields) == 4 {
+				return &Watershed.ParseError{File: fileName, Line: r.line, Err: tooMany}
+			}
+			return err
+		}
+	}
+
+	// 读取可选的控制断面
+	n.Gauges = nil
+	if next < len(rows) {
+		r, _ = nextRow()
+		if len(r.fields) != 1 {
+			return &Watershed.ParseError{File: fileName, Line: r.line, Err: fmt.Errorf("控制断面个数行应只有1列，实际为%d列，%w", len(r.fields), tooMany)}
+		}
+		ng, err := atoi(r, 0)
+		if err != nil {
+			return err
+		}
+		if ng < 0 {
+			return &Watershed.ParseError{File: fileName, Line: r.line, Column: 1, Err: fmt.Errorf("控制断面个数%d不能为负", ng)}
+		}
+		for i := 0; i < ng; i++ {
+			if r, err = nextRow(); err != nil {
+				return err
+			}
+			if len(r.fields) != 2 {
+				return &Watershed.ParseError{File: fileName, Line: r.line, Err: fmt.Errorf("控制断面应有2列，实际为%d列", len(r.fields))}
+			}
+			gauge := Gauge{Name: r.fields[0]}
+			if gauge.Reach, err = atoi(r, 1); err != nil {
+				return err
+			}
+			n.Gauges = append(n.Gauges, gauge)
+		}
+	}
+	if next < len(rows) {
+		return &Watershed.ParseError{File: fileName, Line: rows[next].line, Err: fmt.Errorf("文件末尾有多余的行")}
+	}
+
+	if err := n.build(); err != nil {
+		return &Watershed.ParseError{File: fileName, Err: err}
+	}
+	return nil
+}
+
+// build 检查河网拓扑并确定由上游到下游的演算顺序
+func (n *Network) build() error {
+	n.index = make(map[int]int, len(n.Reaches))
+	for i, reach := range n.Reaches {
+		if reach.ID <= 0 {
+			return fmt.Errorf("河段编号%d应大于0", reach.ID)
+		}
+		if _, ok := n.index[reach.ID]; ok {
+			return fmt.Errorf("河段编号%d重复", reach.ID)
+		}
+		n.index[reach.ID] = i
+	}
+
+	outlets := 0
+	upstream := make([]int, len(n.Reaches)) // 各河段的上游河段个数
+	for _, reach := range n.Reaches {
+		if reach.Downstream == 0 {
+			outlets++
+			continue
+		}
+		d, ok := n.index[reach.Downstream]
+		if !ok {
+			return fmt.Errorf("河段%d的下游河段%d不存在", reach.ID, reach.Downstream)
+		}
+		upstream[d]++
+	}
+	if outlets != 1 {
+		return fmt.Errorf("河网应有且只有一个流域出口河段，实际为%d个", outlets)
+	}
+
+	for w, id := range n.UnitReach {
+		if _, ok := n.index[id]; !ok {
+			return fmt.Errorf("第%d个单元流域汇入的河段%d不存在", w+1, id)
+		}
+	}
+	for _, gauge := range n.Gauges {
+		if _, ok := n.index[gauge.Reach]; !ok {
+			return fmt.Errorf("控制断面%s所在的河段%d不存在", gauge.Name, gauge.Reach)
+		}
+	}
+
+	// 拓扑排序，由最上游河段开始
+	n.order = n.order[:0]
+	var queue []int
+	for i := range n.Reaches {
+		if upstream[i] == 0 {
+			queue = append(queue, i)
+		}
+	}
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		n.order = append(n.order, i)
+		if d := n.Reaches[i].Downstream; d != 0 {
+			upstream[n.index[d]]--
+			if upstream[n.index[d]] == 0 {
+				queue = append(queue, n.index[d])
+			}
+		}
+	}
+	if len(n.order) != len(n.Reaches) {
+		return fmt.Errorf("河网中存在环")
+	}
+
+	return nil
+}
+
+// Check 检查河网与单元流域个数、模型参数及时段长是否相容
+func (n *Network) Check(nw int, parameter *Data.Parameter, dt float64) error {
+	if n.index == nil {
+		if err := n.build(); err != nil {
+			return err
+		}
+	}
+	if len(n.UnitReach) != nw {
+		return fmt.Errorf("河网中给出%d个单元流域汇入的河段，单元流域为%d个", len(n.UnitReach), nw)
+	}
+	for _, reach := range n.Reaches {
+		p := n.ReachParameter(reach, parameter)
+		if _, err := Data.ReachCount(p.KE, dt); err != nil {
+			return fmt.Errorf("河段%d: %w", reach.ID, err)
+		}
+		if p.XE < 0 || p.XE > 0.5 {
+			return fmt.Errorf("河段%d: XE=%g应在[0, 0.5]内", reach.ID, p.XE)
+		}
+	}
+	return nil
+}
+
+// Order 返回按上游到下游排列的河段下标
+func (n *Network) Order() []int {
+	return n.order
+}
+
+// Index 返回河段编号对应的Reaches下标
+func (n *Network) Index(id int) int {
+	return n.index[id]
+}
+
+// ReachParameter 返回河段的马斯京根法参数，未给出的KE、XE取模型参数
+func (n *Network) ReachParameter(reach Reach, parameter *Data.Parameter) *Data.Parameter {
+	p := &Data.Parameter{KE: reach.KE, XE: reach.XE}
+	if math.IsNaN(p.KE) {
+		p.KE = parameter.KE
+	}
+	if math.IsNaN(p.XE) {
+		p.XE = parameter.XE
+	}
+	return p
+}
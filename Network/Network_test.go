@@ -0,0 +1,135 @@
+package Network
+
+import (
+	"demo2/Data"
+	"demo2/Watershed"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const example = `3                  //河段数
+1	3	24	0.3    //河段编号 下游河段编号 KE XE
+2	3	24	-
+3	0	-	-
+1 1 2 2 3          //各单元流域汇入的河段编号
+2                  //控制断面数
+G1	1
+G2	3
+`
+
+// readString 将内容写入临时文件后读取河网
+func readString(t *testing.T, content string) (*Network, string, error) {
+	t.Helper()
+	fileName := filepath.Join(t.TempDir(), NetworkFile)
+	if err := os.WriteFile(fileName, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	n := &Network{}
+	return n, fileName, n.ReadFile(fileName)
+}
+
+func TestReadFile(t *testing.T) {
+	n, _, err := readString(t, example)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(n.Reaches) != 3 || n.Reaches[0] != (Reach{ID: 1, Downstream: 3, KE: 24, XE: 0.3}) {
+		t.Errorf("河段为%+v", n.Reaches)
+	}
+	if !math.IsNaN(n.Reaches[1].XE) || !math.IsNaN(n.Reaches[2].KE) {
+		t.Errorf("“-”应读为NaN，河段为%+v", n.Reaches)
+	}
+	if !reflect.DeepEqual(n.UnitReach, []int{1, 1, 2, 2, 3}) {
+		t.Errorf("单元流域汇入的河段为%v", n.UnitReach)
+	}
+	if !reflect.DeepEqual(n.Gauges, []Gauge{{"G1", 1}, {"G2", 3}}) {
+		t.Errorf("控制断面为%v", n.Gauges)
+	}
+	// 出口河段3须在上游河段1、2之后演算
+	if order := n.Order(); len(order) != 3 || order[2] != n.Index(3) {
+		t.Errorf("演算顺序为%v", order)
+	}
+
+	p := n.ReachParameter(n.Reaches[2], &Data.Parameter{KE: 12, XE: 0.2})
+	if p.KE != 12 || p.XE != 0.2 {
+		t.Errorf("河段3的参数为KE=%g、XE=%g，应取模型参数", p.KE, p.XE)
+	}
+}
+
+// 控制断面可以省略
+func TestReadFileWithoutGauges(t *testing.T) {
+	n, _, err := readString(t, "1\n1 0 - -\n1 1\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Gauges != nil || len(n.Reaches) != 1 || len(n.UnitReach) != 2 {
+		t.Errorf("河网为%+v", n)
+	}
+}
+
+func TestReadFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     string // 错误信息中文件名之后的部分
+	}{
+		{"空文件", "", ": 文件行数不足"},
+		{"河段数无效", "x\n", ":1:1: "},
+		{"河段数为负", "-1\n", ":1:1: 河段个数-1应为正数"},
+		{"河段数为0", "0\n1\n", ":1:1: 河段个数0应为正数"},
+		{"河段行数不足", "2\n1 0 - -\n", ":2: 文件行数不足"},
+		{"河段列数不符", "1\n1 0 -\n1\n", ":2: 河段应有4列，实际为3列"},
+		{"下游河段编号无效", "1\n1 a - -\n1\n", ":2:2: "},
+		{"KE无效", "1\n1 0 x -\n1\n", ":2:3: "},
+		{"缺少单元流域汇入的河段", "1\n1 0 - -\n", ":2: 文件行数不足"},
+		{"单元流域汇入的河段无效", "1\n1 0 - -\n1 b\n", ":3:2: "},
+		{"控制断面数为负", "1\n1 0 - -\n1\n-2\n", ":4:1: 控制断面个数-2不能为负"},
+		{"控制断面列数不符", "1\n1 0 - -\n1\n1\nG1\n", ":5: 控制断面应有2列，实际为1列"},
+		{"河段编号重复", "2\n1 0 - -\n1 0 - -\n1\n", ": 河段编号1重复"},
+		{"河段编号不为正", "1\n0 0 - -\n0\n", ": 河段编号0应大于0"},
+		{"下游河段不存在", "2\n1 5 - -\n2 0 - -\n1\n", ": 河段1的下游河段5不存在"},
+		{"多个出口", "2\n1 0 - -\n2 0 - -\n1\n", ": 河网应有且只有一个流域出口河段，实际为2个"},
+		{"存在环", "3\n1 2 - -\n2 1 - -\n3 0 - -\n1\n", ": 河网中存在环"},
+		{"汇入的河段不存在", "1\n1 0 - -\n2\n", ": 第1个单元流域汇入的河段2不存在"},
+		{"断面所在河段不存在", "1\n1 0 - -\n1\n1\nG1 3\n", ": 控制断面G1所在的河段3不存在"},
+		{"河段行多于河段数", "1\n1 0 - -\n2 1 24 -\n1 2\n", ":3: 河段行数多于声明的河段个数1"},
+		{"河段行多于河段数且均为整数", "1\n1 0 - -\n2 1 24 0\n1 2\n", ":4: 控制断面个数行应只有1列，实际为2列，河段行数多于声明的河段个数1"},
+		{"控制断面之后有多余的行", "1\n1 0 - -\n1\n1\nG1 1\nG2 1\n", ":6: 文件末尾有多余的行"},
+		{"没有控制断面时有多余的行", "1\n1 0 - -\n1\n0\nG1 1\n", ":5: 文件末尾有多余的行"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, fileName, err := readString(t, tt.content)
+			var parseErr *Watershed.ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("错误为%v，应为ParseError", err)
+			}
+			if !strings.HasPrefix(err.Error(), fileName+tt.err) {
+				t.Errorf("错误为%q，应以%q开头", err.Error(), fileName+tt.err)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	n, _, err := readString(t, example)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parameter := &Data.Parameter{KE: 24, XE: 0.3}
+	if err := n.Check(5, parameter, 24); err != nil {
+		t.Errorf("河网应与5个单元流域相容: %v", err)
+	}
+	if err := n.Check(4, parameter, 24); err == nil {
+		t.Error("单元流域个数不符时应返回错误")
+	}
+	n.Reaches[0].XE = 0.6
+	if err := n.Check(5, parameter, 24); err == nil {
+		t.Error("XE超出[0, 0.5]时应返回错误")
+	}
+}
@@ -0,0 +1,319 @@
+package Objective
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Function 参数率定的目标函数，函数值越小表示模拟越好
+type Function interface {
+	Name() string                                   // 目标函数名称
+	Evaluate(simulated, measured []float64) float64 // 计算目标函数值
+}
+
+// metric 由评价指标构造的目标函数
+type metric struct {
+	name string // 名称，用于按名称选择目标函数
+	expr string // 函数值的含义，如“1-NSE”
+	fn   func(simulated, measured []float64) float64
+}
+
+func (m *metric) Name() string {
+	return m.name
+}
+
+func (m *metric) Evaluate(simulated, measured []float64) float64 {
+	return m.fn(simulated, measured)
+}
+
+// 内置目标函数，均为越小越好
+// 序列退化（无有效时段、实测值为常数或全为零等）使指标无定义时，目标函数值为正无穷
+var (
+	NSEObjective    Function = &metric{"NSE", "1-NSE", func(s, m []float64) float64 { return 1 - NSE(s, m) }}
+	KGEObjective    Function = &metric{"KGE", "1-KGE", func(s, m []float64) float64 { return 1 - KGE(s, m) }}
+	LogNSEObjective Function = &metric{"LogNSE", "1-LogNSE", func(s, m []float64) float64 { return 1 - LogNSE(s, m) }}
+	RMSEObjective   Function = &metric{"RMSE", "RMSE", RMSE}
+	PBIASObjective  Function = &metric{"PBIAS", "|PBIAS|", func(s, m []float64) float64 { return math.Abs(PBIAS(s, m)) }}
+	PeakObjective   Function = &metric{"Peak", "|Peak|", func(s, m []float64) float64 { return math.Abs(PeakError(s, m)) }}
+	VolumeObjective Function = &metric{"Volume", "|Volume|", func(s, m []float64) float64 { return math.Abs(VolumeError(s, m)) }}
+	FDCObjective    Function = &metric{"FDC", "FDC", FDCError}
+)
+
+// builtins 按名称（不区分大小写）索引的内置目标函数
+var builtins = map[string]Function{}
+
+func init() {
+	for _, f := range []Function{NSEObjective, KGEObjective, LogNSEObjective, RMSEObjective,
+		PBIASObjective, PeakObjective, VolumeObjective, FDCObjective} {
+		builtins[strings.ToUpper(f.Name())] = f
+	}
+}
+
+// Names 返回所有内置目标函数的名称
+func Names() []string {
+	names := make([]string, 0, len(builtins))
+	for _, f := range builtins {
+		names = append(names, f.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ByName 按名称返回目标函数，名称不区分大小写
+// 多目标加权组合写作“名称:权重,名称:权重”，如“NSE:0.7,Volume:0.3”
+func ByName(spec string) (Function, error) {
+	spec = strings.TrimSpace(spec)
+	if !strings.ContainsAny(spec, ":,") {
+		f, ok := builtins[strings.ToUpper(spec)]
+		if !ok {
+			return nil, fmt.Errorf("未知的目标函数: %s，可选: %s", spec, strings.Join(Names(), ", "))
+		}
+		return f, nil
+	}
+
+	var functions []Function
+	var weights []float64
+	for _, item := range strings.Split(spec, ",") {
+		name, weight, found := strings.Cut(strings.TrimSpace(item), ":")
+		w := 1.0
+		if found {
+			var err error
+			if w, err = strconv.ParseFloat(strings.TrimSpace(weight), 64); err != nil {
+				return nil, fmt.Errorf("目标函数%s的权重无效: %v", name, err)
+			}
+		}
+		f, err := ByName(name)
+		if err != nil {
+			return nil, err
+		}
+		functions = append(functions, f)
+		weights = append(weights, w)
+	}
+
+	return NewWeighted(functions, weights), nil
+}
+
+// Expression 返回目标函数值的含义，如NSE目标函数的值为“1-NSE”，加权组合为“0.7*(1-NSE)+0.3*|Volume|”
+// 非内置的目标函数返回其名称
+func Expression(f Function) string {
+	switch f := f.(type) {
+	case *metric:
+		return f.expr
+	case *Weighted:
+		items := make([]string, len(f.Functions))
+		for i, fn := range f.Functions {
+			expr := Expression(fn)
+			if strings.ContainsAny(expr, "+-") {
+				expr = "(" + expr + ")"
+			}
+			items[i] = fmt.Sprintf("%g*%s", f.Weights[i], expr)
+		}
+		return strings.Join(items, "+")
+	}
+	return f.Name()
+}
+
+// Weighted 多个目标函数的加权和
+type Weighted struct {
+	Functions []Function
+	Weights   []float64
+}
+
+// NewWeighted 创建加权组合目标函数
+func NewWeighted(functions []Function, weights []float64) *Weighted {
+	return &Weighted{Functions: functions, Weights: weights}
+}
+
+func (w *Weighted) Name() string {
+	items := make([]string, len(w.Functions))
+	for i, f := range w.Functions {
+		items[i] = fmt.Sprintf("%s:%g", f.Name(), w.Weights[i])
+	}
+	return strings.Join(items, ",")
+}
+
+// Evaluate 返回各目标函数值的加权和，权重为0的目标函数不参与计算，其值为无穷时也不会使结果为NaN
+func (w *Weighted) Evaluate(simulated, measured []float64) float64 {
+	sum := 0.0
+	for i, f := range w.Functions {
+		if w.Weights[i] == 0 {
+			continue
+		}
+		sum += w.Weights[i] * f.Evaluate(simulated, measured)
+	}
+	return sum
+}
+
+// NSE 计算Nash-Sutcliffe效率系数，无有效时段或实测值为常数时为负无穷
+func NSE(simulated, measured []float64) float64 {
+	simulated, measured = Paired(simulated, measured)
+
+	var sumSquaredError, sumSquaredDeviation float64
+
+	// 计算观测流量平均值
+	measuredMean := mean(measured)
+
+	for i := range measured {
+		sumSquaredError += math.Pow(measured[i]-simulated[i], 2)
+		sumSquaredDeviation += math.Pow(measured[i]-measuredMean, 2)
+	}
+	if sumSquaredDeviation == 0 {
+		return math.Inf(-1)
+	}
+
+	return 1 - (sumSquaredError / sumSquaredDeviation)
+}
+
+// KGE 计算Kling-Gupta效率系数，无有效时段、模拟值或实测值为常数、实测均值为零时为负无穷
+func KGE(simulated, measured []float64) float64 {
+	simulated, measured = Paired(simulated, measured)
+
+	simulatedMean := mean(simulated)
+	measuredMean := mean(measured)
+
+	var cov, simulatedVar, measuredVar float64
+	for i := range measured {
+		ds := simulated[i] - simulatedMean
+		dm := measured[i] - measuredMean
+		cov += ds * dm
+		simulatedVar += ds * ds
+		measuredVar += dm * dm
+	}
+	if simulatedVar == 0 || measuredVar == 0 || measuredMean == 0 {
+		return math.Inf(-1)
+	}
+
+	r := cov / math.Sqrt(simulatedVar*measuredVar) // 相关系数
+	alpha := math.Sqrt(simulatedVar / measuredVar) // 标准差之比
+	beta := simulatedMean / measuredMean           // 均值之比
+
+	return 1 - math.Sqrt(math.Pow(r-1, 2)+math.Pow(alpha-1, 2)+math.Pow(beta-1, 2))
+}
+
+// LogNSE 计算对数流量的Nash-Sutcliffe效率系数，侧重枯水过程
+// 为避免零流量取对数，流量加上实测均值的1%，实测均值不为正时为负无穷
+func LogNSE(simulated, measured []float64) float64 {
+	simulated, measured = Paired(simulated, measured)
+
+	eps := mean(measured) / 100
+	if eps <= 0 {
+		return math.Inf(-1)
+	}
+	logSimulated := make([]float64, len(measured))
+	logMeasured := make([]float64, len(measured))
+	for i := range measured {
+		logSimulated[i] = math.Log(math.Max(simulated[i], 0) + eps)
+		logMeasured[i] = math.Log(math.Max(measured[i], 0) + eps)
+	}
+	return NSE(logSimulated, logMeasured)
+}
+
+// RMSE 计算均方根误差，m3/s，无有效时段时为正无穷
+func RMSE(simulated, measured []float64) float64 {
+	simulated, measured = Paired(simulated, measured)
+	if len(measured) == 0 {
+		return math.Inf(1)
+	}
+
+	sum := 0.0
+	for i := range measured {
+		sum += math.Pow(simulated[i]-measured[i], 2)
+	}
+	return math.Sqrt(sum / float64(len(measured)))
+}
+
+// PBIAS 计算百分比偏差，%，正值表示模拟偏大，实测总量为零时为正无穷
+func PBIAS(simulated, measured []float64) float64 {
+	return 100 * VolumeError(simulated, measured)
+}
+
+// PeakError 计算洪峰相对误差，正值表示模拟洪峰偏大，无有效时段或实测洪峰为零时为正无穷
+func PeakError(simulated, measured []float64) float64 {
+	simulated, measured = Paired(simulated, measured)
+
+	measuredPeak := maxSlice(measured)
+	if len(measured) == 0 || measuredPeak == 0 {
+		return math.Inf(1)
+	}
+	return (maxSlice(simulated) - measuredPeak) / measuredPeak
+}
+
+// VolumeError 计算径流总量相对误差，正值表示模拟水量偏大，实测总量为零时为正无穷
+func VolumeError(simulated, measured []float64) float64 {
+	simulated, measured = Paired(simulated, measured)
+
+	var sumSimulated, sumMeasured float64
+	for i := range measured {
+		sumSimulated += simulated[i]
+		sumMeasured += measured[i]
+	}
+	if sumMeasured == 0 {
+		return math.Inf(1)
+	}
+	return (sumSimulated - sumMeasured) / sumMeasured
+}
+
+// FDCError 计算流量历时曲线的相对误差，即模拟与实测流量分别排序后的绝对误差之和与实测总量之比，
+// 实测总量为零时为正无穷
+func FDCError(simulated, measured []float64) float64 {
+	simulated, measured = Paired(simulated, measured)
+
+	sortedSimulated := append([]float64(nil), simulated...)
+	sortedMeasured := append([]float64(nil), measured...)
+	sort.Float64s(sortedSimulated)
+	sort.Float64s(sortedMeasured)
+
+	var sumError, sumMeasured float64
+	for i := range sortedMeasured {
+		sumError += math.Abs(sortedSimulated[i] - sortedMeasured[i])
+		sumMeasured += sortedMeasured[i]
+	}
+	if sumMeasured == 0 {
+		return math.Inf(1)
+	}
+	return sumError / sumMeasured
+}
+
+// Paired 返回模拟值与实测值均不缺测的时段组成的序列，缺测以NaN表示
+// 各评价指标只在这些时段上计算
+func Paired(simulated, measured []float64) ([]float64, []float64) {
+	n := min(len(simulated), len(measured))
+	pairedSimulated := make([]float64, 0, n)
+	pairedMeasured := make([]float64, 0, n)
+	for i := 0; i < n; i++ {
+		if math.IsNaN(simulated[i]) || math.IsNaN(measured[i]) {
+			continue
+		}
+		pairedSimulated = append(pairedSimulated, simulated[i])
+		pairedMeasured = append(pairedMeasured, measured[i])
+	}
+	return pairedSimulated, pairedMeasured
+}
+
+// 辅助函数
+func mean(s []float64) float64 {
+	if len(s) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range s {
+		sum += v
+	}
+	return sum / float64(len(s))
+}
+
+func maxSlice(s []float64) float64 {
+	if len(s) == 0 {
+		return 0
+	}
+	max := s[0]
+	for _, v := range s {
+		if v > max {
+			max = v
+		}
+	}
+	return max
+}
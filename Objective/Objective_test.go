@@ -0,0 +1,133 @@
+package Objective
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	measured := []float64{1, 2, 3, 4}
+	tests := []struct {
+		name      string
+		fn        func(simulated, measured []float64) float64
+		simulated []float64
+		want      float64
+	}{
+		{"NSE完全吻合", NSE, []float64{1, 2, 3, 4}, 1},
+		{"NSE均值", NSE, []float64{2.5, 2.5, 2.5, 2.5}, 0},
+		{"KGE完全吻合", KGE, []float64{1, 2, 3, 4}, 1},
+		{"LogNSE完全吻合", LogNSE, []float64{1, 2, 3, 4}, 1},
+		{"RMSE", RMSE, []float64{2, 3, 4, 5}, 1},
+		{"PBIAS", PBIAS, []float64{2, 3, 4, 5}, 40},
+		{"PeakError", PeakError, []float64{1, 2, 3, 5}, 0.25},
+		{"VolumeError", VolumeError, []float64{0, 1, 2, 3}, -0.4},
+		{"FDCError", FDCError, []float64{4, 3, 2, 1}, 0},
+		{"缺测时段不参与计算", NSE, []float64{1, math.NaN(), 3, 4}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.fn(tt.simulated, measured); math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("得到%g，应为%g", got, tt.want)
+			}
+		})
+	}
+}
+
+// 序列退化时各指标不应为NaN，对应的目标函数值应为正无穷
+func TestDegenerateSeries(t *testing.T) {
+	series := []struct {
+		name                string
+		simulated, measured []float64
+	}{
+		{"实测值为常数", []float64{1, 2, 3}, []float64{2, 2, 2}},
+		{"实测值全为零", []float64{1, 2, 3}, []float64{0, 0, 0}},
+		{"模拟值为常数且实测值全为零", []float64{0, 0, 0}, []float64{0, 0, 0}},
+		{"无有效时段", []float64{math.NaN(), 1}, []float64{1, math.NaN()}},
+		{"空序列", nil, nil},
+	}
+	for _, s := range series {
+		for _, f := range []Function{NSEObjective, KGEObjective, LogNSEObjective, RMSEObjective,
+			PBIASObjective, PeakObjective, VolumeObjective, FDCObjective} {
+			got := f.Evaluate(s.simulated, s.measured)
+			if math.IsNaN(got) {
+				t.Errorf("%s: 目标函数%s为NaN", s.name, f.Name())
+			}
+		}
+		for _, f := range []Function{NSEObjective, KGEObjective, LogNSEObjective} {
+			if got := f.Evaluate(s.simulated, s.measured); !math.IsInf(got, 1) {
+				t.Errorf("%s: 目标函数%s为%g，应为正无穷", s.name, f.Name(), got)
+			}
+		}
+	}
+}
+
+// 实测总量为零时相对误差无定义
+func TestZeroMeasuredVolume(t *testing.T) {
+	simulated, measured := []float64{1, 2}, []float64{0, 0}
+	for _, f := range []Function{PBIASObjective, PeakObjective, VolumeObjective, FDCObjective} {
+		if got := f.Evaluate(simulated, measured); !math.IsInf(got, 1) {
+			t.Errorf("目标函数%s为%g，应为正无穷", f.Name(), got)
+		}
+	}
+}
+
+// 模拟值为常数时KGE的相关系数无定义
+func TestKGEConstantSimulation(t *testing.T) {
+	if got := KGEObjective.Evaluate([]float64{3, 3, 3}, []float64{1, 2, 3}); !math.IsInf(got, 1) {
+		t.Errorf("得到%g，应为正无穷", got)
+	}
+}
+
+func TestByName(t *testing.T) {
+	tests := []struct {
+		spec string
+		expr string
+		ok   bool
+	}{
+		{"nse", "1-NSE", true},
+		{"KGE", "1-KGE", true},
+		{"NSE:0.7,Volume:0.3", "0.7*(1-NSE)+0.3*|Volume|", true},
+		{"RMSE,Peak", "1*RMSE+1*|Peak|", true},
+		{"XYZ", "", false},
+		{"NSE:abc", "", false},
+	}
+	for _, tt := range tests {
+		f, err := ByName(tt.spec)
+		if (err == nil) != tt.ok {
+			t.Errorf("ByName(%q)的错误为%v", tt.spec, err)
+			continue
+		}
+		if err == nil && Expression(f) != tt.expr {
+			t.Errorf("ByName(%q)的函数值含义为%q，应为%q", tt.spec, Expression(f), tt.expr)
+		}
+	}
+}
+
+func TestWeighted(t *testing.T) {
+	f, err := ByName("NSE:0.5,Volume:2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	simulated, measured := []float64{2, 3, 4, 5}, []float64{1, 2, 3, 4}
+	want := 0.5*(1-NSE(simulated, measured)) + 2*math.Abs(VolumeError(simulated, measured))
+	if got := f.Evaluate(simulated, measured); math.Abs(got-want) > 1e-12 {
+		t.Errorf("得到%g，应为%g", got, want)
+	}
+}
+
+// 权重为0的目标函数值为无穷时不参与加权，结果不应为NaN
+func TestWeightedZeroWeight(t *testing.T) {
+	f, err := ByName("NSE:0,Volume:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 实测值为常数时NSE为负无穷，1-NSE为正无穷
+	simulated, measured := []float64{2, 3, 4}, []float64{3, 3, 3}
+	if !math.IsInf(f.(*Weighted).Functions[0].Evaluate(simulated, measured), 1) {
+		t.Fatal("实测值为常数时1-NSE应为正无穷")
+	}
+	want := math.Abs(VolumeError(simulated, measured))
+	if got := f.Evaluate(simulated, measured); got != want {
+		t.Errorf("得到%g，应为%g", got, want)
+	}
+}
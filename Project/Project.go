@@ -0,0 +1,293 @@
+package Project
+
+import (
+	"bytes"
+	"demo2/Calibration"
+	"demo2/Data"
+	"demo2/Network"
+	"demo2/Watershed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 项目配置文件名
+const ConfigFile = "project.json"
+
+// 项目配置格式版本，配置结构不兼容地变化时递增
+const Version = 1
+
+// Config 项目配置，以一个JSON文件描述一次计算的数据文件、时段、预热期、初始状态、模型参数、率定设置及输出
+// 配置中的相对路径均相对于配置文件所在目录
+type Config struct {
+	Version     int                   `json:"version"`               // 配置格式版本
+	Name        string                `json:"name,omitempty"`        // 项目名称
+	Data        Files                 `json:"data"`                  // 数据文件
+	Time        Time                  `json:"time"`                  // 时段长及计算时段
+	Fill        string                `json:"fill,omitempty"`        // 降雨、蒸发缺测插补方法：zero、linear或nearest
+	Warmup      int                   `json:"warmup,omitempty"`      // 预热期时段数
+	Spinup      int                   `json:"spinup,omitempty"`      // 预热期最大重复计算次数
+	Parameters  map[string]float64    `json:"parameters"`            // 模型参数，参数名到参数值，须给出全部参数
+	Calibration *Calibration.Settings `json:"calibration,omitempty"` // 参数率定设置，不率定时可省略
+	Output      Output                `json:"output"`                // 输出文件
+
+	dir string // 配置文件所在目录
+}
+
+// Files 数据文件，格式与同名的传统文本文件相同
+type Files struct {
+	Watershed     string `json:"watershed"`                // 流域信息，同watershed.txt
+	P             string `json:"p"`                        // 降雨，同P.txt
+	EM            string `json:"em"`                       // 蒸发，同EM.txt
+	Observed      string `json:"observed,omitempty"`       // 实测流量，同observed_Q.txt，可省略
+	InitialState  string `json:"initial_state,omitempty"`  // 初始状态，同initstate.txt，省略时取模型默认初始状态
+	UnitParameter string `json:"unit_parameter,omitempty"` // 单元流域参数，同unitparameter.txt，省略时各单元流域共用模型参数
+	Network       string `json:"network,omitempty"`        // 河网，同network.txt，省略时各单元流域出流分别演算至流域出口
+}
+
+// Time 时段设置
+type Time struct {
+	Step  string `json:"step,omitempty"`  // 时段长，小时数或带单位的时长，如“24”“6h”，数据文件带有时间时须一致
+	Start string `json:"start,omitempty"` // 没有时间信息的数据文件的首时段时间
+	From  string `json:"from,omitempty"`  // 计算时段的首时段时间，省略时从数据首时段开始
+	To    string `json:"to,omitempty"`    // 计算时段的末时段时间（含），省略时至数据末时段
+}
+
+// Output 输出文件，省略时取传统文件名
+type Output struct {
+	Q           string `json:"q,omitempty"`           // 流域出口断面流量，默认Q.txt
+	Gauges      string `json:"gauges,omitempty"`      // 控制断面流量，默认gauges.txt
+	Snapshot    string `json:"snapshot,omitempty"`    // 计算结束时的状态快照，省略时不输出
+	Calibration string `json:"calibration,omitempty"` // 率定结果，默认sceout.txt
+}
+
+// Inputs 按配置读取的模型输入
+type Inputs struct {
+	Watershed *Watershed.Watershed // 流域信息，已由Watershed.Calculate计算出各单元流域降雨、蒸发
+	IO        *Watershed.IO        // 驱动数据及实测流量
+	Parameter *Data.Parameter      // 模型参数
+	Initial   []*Data.State        // 各单元流域初始状态，未给出时为nil
+	Units     Data.UnitParameters  // 各单元流域参数，未给出时为nil
+	Network   *Network.Network     // 河网拓扑，未给出时为nil
+}
+
+// Read 读取项目配置，配置中有未知的字段时返回错误
+func Read(fileName string) (*Config, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Config{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(c); err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	c.dir = filepath.Dir(fileName)
+	if err := c.Check(); err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	return c, nil
+}
+
+// WriteFile 以JSON格式输出项目配置
+func (c *Config) WriteFile(fileName string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, append(data, '\n'), 0644)
+}
+
+// Check 检查配置的版本、必需的数据文件、时间格式及参数名，返回全部问题
+func (c *Config) Check() error {
+	var errs []error
+	if c.Version != Version {
+		errs = append(errs, fmt.Errorf("配置格式版本%d与当前版本%d不一致", c.Version, Version))
+	}
+	for _, f := range []struct{ name, file string }{{"watershed", c.Data.Watershed}, {"p", c.Data.P}, {"em", c.Data.EM}} {
+		if f.file == "" {
+			errs = append(errs, fmt.Errorf("data中未给出%s", f.name))
+		}
+	}
+	if c.Time.Step != "" {
+		if _, err := Watershed.ParseStep(c.Time.Step); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, t := range []string{c.Time.Start, c.Time.From, c.Time.To} {
+		if t != "" {
+			if _, err := Watershed.ParseTime(t); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if _, err := c.Parameter(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Path 返回配置中的文件相对于配置文件所在目录的路径，name为空时返回空
+func (c *Config) Path(name string) string {
+	if name == "" || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(c.dir, name)
+}
+
+// Parameter 返回配置中的模型参数，须给出全部参数且不能有未知的参数名
+func (c *Config) Parameter() (*Data.Parameter, error) {
+	var errs []error
+	p := &Data.Parameter{}
+	for name, value := range c.Parameters {
+		if !p.Set(name, value) {
+			errs = append(errs, fmt.Errorf("parameters中的%s不是模型参数", name))
+		}
+	}
+	var missing []string
+	for _, name := range Data.ParameterNames() {
+		if _, ok := c.Parameters[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		errs = append(errs, fmt.Errorf("parameters中缺少参数%s", strings.Join(missing, "、")))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return p, nil
+}
+
+// SetParameter 以模型参数设置配置中的参数
+func (c *Config) SetParameter(p *Data.Parameter) {
+	c.Parameters = make(map[string]float64, Data.NumParameters)
+	for _, name := range Data.ParameterNames() {
+		c.Parameters[name], _ = p.Get(name)
+	}
+}
+
+// Load 按配置读取流域信息、驱动数据、初始状态、单元流域参数及河网，并截取计算时段
+func (c *Config) Load() (*Inputs, error) {
+	parameter, err := c.Parameter()
+	if err != nil {
+		return nil, err
+	}
+	in := &Inputs{Watershed: &Watershed.Watershed{}, IO: &Watershed.IO{FillMethod: c.Fill}, Parameter: parameter}
+	if err := in.Watershed.ReadFile(c.Path(c.Data.Watershed)); err != nil {
+		return nil, err
+	}
+
+	files := Watershed.Files{P: c.Path(c.Data.P), EM: c.Path(c.Data.EM), Observed: c.Path(c.Data.Observed)}
+	if c.Time.Step != "" {
+		files.Step, _ = Watershed.ParseStep(c.Time.Step)
+	}
+	if c.Time.Start != "" {
+		files.Start, _ = Watershed.ParseTime(c.Time.Start)
+	}
+	if err := in.IO.ReadFiles(files); err != nil {
+		return nil, err
+	}
+	var from, to time.Time
+	if c.Time.From != "" {
+		from, _ = Watershed.ParseTime(c.Time.From)
+	}
+	if c.Time.To != "" {
+		to, _ = Watershed.ParseTime(c.Time.To)
+	}
+	if err := in.IO.Period(from, to); err != nil {
+		return nil, err
+	}
+	if err := in.Watershed.Calculate(in.IO); err != nil {
+		return nil, err
+	}
+
+	nw := in.Watershed.GetnW()
+	if c.Data.InitialState != "" {
+		if in.Initial, err = Data.ReadInitialStateFile(c.Path(c.Data.InitialState), nw); err != nil {
+			return nil, err
+		}
+	}
+	if c.Data.UnitParameter != "" {
+		if in.Units, err = Data.ReadUnitParameterFile(c.Path(c.Data.UnitParameter), nw); err != nil {
+			return nil, err
+		}
+	}
+	if c.Data.Network != "" {
+		in.Network = &Network.Network{}
+		if err := in.Network.ReadFile(c.Path(c.Data.Network)); err != nil {
+			return nil, err
+		}
+	}
+	return in, nil
+}
+
+// FromLegacy 由数据目录下的传统文本文件生成项目配置：
+// watershed.txt、P.txt、EM.txt及可选的observed_Q.txt、initstate.txt、unitparameter.txt、network.txt作为数据文件，
+// time.txt中的时段长及起始时间、parameter.txt中的模型参数、scein.txt中的率定设置写入配置
+// 生成的配置以相对路径引用数据文件，应保存在该数据目录下
+func FromLegacy(dir string) (*Config, error) {
+	c := &Config{
+		Version: Version,
+		Data:    Files{Watershed: "watershed.txt", P: "P.txt", EM: "EM.txt"},
+		Output:  Output{Q: "Q.txt", Gauges: "gauges.txt", Calibration: "sceout.txt"},
+		dir:     dir,
+	}
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+	for _, name := range []string{c.Data.Watershed, c.Data.P, c.Data.EM} {
+		if !exists(name) {
+			return nil, fmt.Errorf("数据目录%s中没有%s", dir, name)
+		}
+	}
+	if exists("observed_Q.txt") {
+		c.Data.Observed = "observed_Q.txt"
+	}
+	if exists(Data.InitialStateFile) {
+		c.Data.InitialState = Data.InitialStateFile
+	}
+	if exists(Data.UnitParameterFile) {
+		c.Data.UnitParameter = Data.UnitParameterFile
+	}
+	if exists(Network.NetworkFile) {
+		c.Data.Network = Network.NetworkFile
+	}
+
+	start, step, err := Watershed.ReadTimeFile(filepath.Join(dir, "time.txt"))
+	if err != nil {
+		return nil, err
+	}
+	if step > 0 {
+		c.Time.Step = strconv.FormatFloat(step.Hours(), 'g', -1, 64)
+	}
+	if !start.IsZero() {
+		c.Time.Start = Watershed.FormatTime(start)
+	}
+
+	// 没有parameter.txt时参数留空，须另行给出
+	if exists("parameter.txt") {
+		p := &Data.Parameter{}
+		if err := p.ReadFile(filepath.Join(dir, "parameter.txt")); err != nil {
+			return nil, err
+		}
+		c.SetParameter(p)
+	}
+
+	// scein.txt中的缺测插补方法对模拟和率定均适用
+	if exists("scein.txt") {
+		if c.Calibration, err = Calibration.ReadSettings(filepath.Join(dir, "scein.txt")); err != nil {
+			return nil, err
+		}
+		c.Fill, c.Calibration.Fill = c.Calibration.Fill, ""
+	}
+	return c, nil
+}
@@ -0,0 +1,114 @@
+package Project
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadExample(t *testing.T) {
+	c, err := Read("../IOexamples/project示例.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Path(c.Data.P) != filepath.Join("../IOexamples", "P.txt") {
+		t.Errorf("降雨文件路径为%s，应相对于配置文件所在目录", c.Path(c.Data.P))
+	}
+	if abs := "/data/P.txt"; c.Path(abs) != abs || c.Path("") != "" {
+		t.Error("绝对路径及空路径应原样返回")
+	}
+	p, err := c.Parameter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.WM != 120 || p.KE != 24 {
+		t.Errorf("模型参数为%+v", p)
+	}
+	if c.Calibration == nil || len(c.Calibration.Parameters) != 7 {
+		t.Errorf("率定设置为%+v", c.Calibration)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	// config 返回时段设置为timing的配置
+	config := func(timing string) string {
+		return `{"version": 1, "data": {"watershed": "w.txt", "p": "P.txt", "em": "EM.txt"}, "time": {` + timing + `},
+"parameters": {"KC": 1, "UM": 20, "LM": 60, "C": 0.15, "WM": 120, "B": 0.3, "IM": 0.01, "SM": 30, "EX": 1.5,
+"KG": 0.3, "KI": 0.4, "CS": 0.2, "CI": 0.7, "CG": 0.98, "CR": 0.2, "KE": 24, "XE": 0.3}}`
+	}
+	valid := config("")
+	tests := []struct {
+		name    string
+		content string
+		err     string // 错误信息中应包含的内容
+	}{
+		{"JSON格式错误", `{"version": 1,`, "unexpected EOF"},
+		{"未知的字段", `{"version": 1, "datas": {}}`, `unknown field "datas"`},
+		{"版本不一致", strings.Replace(valid, `"version": 1`, `"version": 2`, 1), "配置格式版本2与当前版本1不一致"},
+		{"缺少数据文件", strings.Replace(valid, `"p": "P.txt", `, "", 1), "data中未给出p"},
+		{"时段长无效", config(`"step": "-6h"`), "时段长"},
+		{"时间无效", config(`"from": "2000-13-01"`), "2000-13-01"},
+		{"未知的参数名", strings.Replace(valid, `"KC": 1`, `"KC": 1, "FOO": 1`, 1), "parameters中的FOO不是模型参数"},
+		{"缺少参数", strings.Replace(valid, `"KC": 1, "UM": 20, `, "", 1), "parameters中缺少参数KC、UM"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), ConfigFile)
+			if err := os.WriteFile(fileName, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := Read(fileName)
+			if err == nil || !strings.HasPrefix(err.Error(), fileName+": ") || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("错误为%v，应包含%q", err, tt.err)
+			}
+		})
+	}
+}
+
+// 由传统文本文件生成的配置输出后应能原样读回，并按计算时段读取数据
+func TestFromLegacyLoad(t *testing.T) {
+	dir, err := filepath.Abs("../IOexamples")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := FromLegacy(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Data.InitialState != "" || c.Data.Network != "" || c.Time.Step != "24" || c.Calibration == nil {
+		t.Errorf("生成的配置为%+v", c)
+	}
+	for _, f := range []*string{&c.Data.Watershed, &c.Data.P, &c.Data.EM} {
+		*f = filepath.Join(dir, *f)
+	}
+	c.Time.Start, c.Time.From, c.Time.To = "2000-01-01", "2000-01-11", "2000-01-20"
+
+	fileName := filepath.Join(t.TempDir(), ConfigFile)
+	if err := c.WriteFile(fileName); err != nil {
+		t.Fatal(err)
+	}
+	read, err := Read(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read.dir = c.dir
+	if !reflect.DeepEqual(read, c) {
+		t.Errorf("读回的配置为%+v，应为%+v", read, c)
+	}
+
+	in, err := read.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in.IO.Nrows != 10 || len(in.Watershed.P) != 10 || in.IO.Start.Day() != 11 {
+		t.Errorf("计算时段从%v开始，共%d个时段，应从2000-01-11开始共10个时段", in.IO.Start, in.IO.Nrows)
+	}
+
+	c.Time.From = "1999-01-01"
+	c.Time.To = "1999-12-31"
+	if _, err := c.Load(); err == nil {
+		t.Error("计算时段超出数据范围时应返回错误")
+	}
+}
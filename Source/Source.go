@@ -105,7 +105,8 @@ func (s *Source) Calculate() {
 		s.SMF = s.SMMF / (1.0 + s.EX)
 
 		for i := 1; i <= s.N; i++ {
-			if s.S > s.SMF {
+			if s.S > s.SMF { // 产流面积减小后超出自由水蓄水容量的部分产生地面径流
+				s.RS += (s.S - s.SMF) * s.FR
 				s.S = s.SMF
 			}
 			s.AU = s.SMMF * (1 - math.Pow(1-s.S/s.SMF, 1.0/(1+s.EX)))
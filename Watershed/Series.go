@@ -0,0 +1,364 @@
+package Watershed
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 时间格式，读取时依次尝试，输出时使用第一种
+var TimeLayouts = []string{
+	"2006-01-02T15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"2006/01/02T15:04",
+	"2006/01/02",
+}
+
+// Series 等时段长的时间序列，Values按[时段][站点]存储
+// 文件首行为“行数 列数 [起始时间 时段长]”，或每行首列为时间戳；两者都没有时Start、Step为零值
+type Series struct {
+	Start  time.Time     // 首时段时间
+	Step   time.Duration // 时段长
+	Values [][]float64   // 各时段各站点数值，缺测为NaN
+}
+
+// HasTime 序列是否带有时间信息
+func (s *Series) HasTime() bool {
+	return !s.Start.IsZero() && s.Step > 0
+}
+
+// Len 返回时段数
+func (s *Series) Len() int {
+	return len(s.Values)
+}
+
+// Time 返回第i个时段的时间
+func (s *Series) Time(i int) time.Time {
+	return s.Start.Add(time.Duration(i) * s.Step)
+}
+
+// End 返回最后一个时段之后的时间
+func (s *Series) End() time.Time {
+	return s.Time(s.Len())
+}
+
+// Column 返回第c列的序列
+func (s *Series) Column(c int) []float64 {
+	values := make([]float64, len(s.Values))
+	for i := range s.Values {
+		values[i] = s.Values[i][c]
+	}
+	return values
+}
+
+// ParseTime 按TimeLayouts解析时间
+func ParseTime(token string) (time.Time, error) {
+	for _, layout := range TimeLayouts {
+		if t, err := time.Parse(layout, token); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法解析的时间: %s", token)
+}
+
+// FormatTime 按TimeLayouts中的第一种格式输出时间
+func FormatTime(t time.Time) string {
+	return t.Format(TimeLayouts[0])
+}
+
+// ParseStep 解析时段长，可为小时数（如“24”）或带单位的时长（如“6h”、“30m”）
+func ParseStep(token string) (time.Duration, error) {
+	if hours, err := strconv.ParseFloat(token, 64); err == nil {
+		if hours <= 0 {
+			return 0, fmt.Errorf("时段长应大于0，实际为%s", token)
+		}
+		return time.Duration(hours * float64(time.Hour)), nil
+	}
+	step, err := time.ParseDuration(token)
+	if err != nil || step <= 0 {
+		return 0, fmt.Errorf("无法解析的时段长: %s", token)
+	}
+	return step, nil
+}
+
+// ReadSeries 读取首行为“行数 列数 [起始时间 时段长]”的数据文件
+// 数据以制表符分隔时空单元格视为缺测，否则以空白分隔；缺测以NaN表示
+// 每行首列可为时间戳，此时列数不含时间戳列，各时段时间须等间隔连续
+func ReadSeries(fileName string) (*Series, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() {
+		return nil, &ParseError{File: fileName, Line: 1, Err: fmt.Errorf("缺少“行数 列数”首行")}
+	}
+	series, nrows, ncols, err := parseHeader(stripComment(scanner.Text()))
+	if err != nil {
+		return nil, &ParseError{File: fileName, Line: 1, Err: err}
+	}
+
+	var times []time.Time // 各行的时间戳
+	series.Values = make([][]float64, nrows)
+	for r := 0; r < nrows; r++ {
+		line := r + 2
+		if !scanner.Scan() {
+			return nil, &ParseError{File: fileName, Line: line, Err: fmt.Errorf("应有%d行数据，实际为%d行", nrows, r)}
+		}
+		text := stripComment(scanner.Text())
+		var cells []string
+		if strings.Contains(text, "\t") {
+			cells = strings.Split(text, "\t")
+		} else {
+			cells = strings.Fields(text)
+		}
+
+		// 首行数据的首列能解析为时间时，各行首列均为时间戳
+		if r == 0 && len(cells) > 0 {
+			if _, err := ParseTime(strings.TrimSpace(cells[0])); err == nil {
+				times = make([]time.Time, nrows)
+			}
+		}
+		if times != nil {
+			if len(cells) == 0 {
+				return nil, &ParseError{File: fileName, Line: line, Column: 1, Err: fmt.Errorf("缺少时间戳")}
+			}
+			if times[r], err = ParseTime(strings.TrimSpace(cells[0])); err != nil {
+				return nil, &ParseError{File: fileName, Line: line, Column: 1, Err: err}
+			}
+			cells = cells[1:]
+		}
+
+		// 只有一列时空行视为缺测
+		if ncols == 1 && len(cells) == 0 {
+			cells = []string{""}
+		}
+		// 制表符分隔的行尾可能多出空单元格
+		for len(cells) > ncols && strings.TrimSpace(cells[len(cells)-1]) == "" {
+			cells = cells[:len(cells)-1]
+		}
+		if len(cells) != ncols {
+			return nil, &ParseError{File: fileName, Line: line, Err: fmt.Errorf("应有%d列，实际为%d列", ncols, len(cells))}
+		}
+
+		series.Values[r] = make([]float64, ncols)
+		for c, cell := range cells {
+			if series.Values[r][c], err = ParseValue(cell); err != nil {
+				column := c + 1
+				if times != nil {
+					column++
+				}
+				return nil, &ParseError{File: fileName, Line: line, Column: column, Err: err}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, &ParseError{File: fileName, Err: err}
+	}
+
+	if times != nil {
+		if err := series.setTimes(times); err != nil {
+			return nil, &ParseError{File: fileName, Err: err}
+		}
+	}
+
+	return series, nil
+}
+
+// parseHeader 解析“行数 列数 [起始时间 时段长]”首行
+func parseHeader(text string) (*Series, int, int, error) {
+	fields := strings.Fields(text)
+	if len(fields) < 2 {
+		return nil, 0, 0, fmt.Errorf("首行应为“行数 列数 [起始时间 时段长]”")
+	}
+	nrows, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("行数无效: %v", err)
+	}
+	ncols, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("列数无效: %v", err)
+	}
+	if nrows < 0 || ncols <= 0 {
+		return nil, 0, 0, fmt.Errorf("行数%d或列数%d无效", nrows, ncols)
+	}
+
+	series := &Series{}
+	switch len(fields) {
+	case 2:
+	case 4:
+		if series.Start, err = ParseTime(fields[2]); err != nil {
+			return nil, 0, 0, err
+		}
+		if series.Step, err = ParseStep(fields[3]); err != nil {
+			return nil, 0, 0, err
+		}
+	default:
+		return nil, 0, 0, fmt.Errorf("首行应为“行数 列数 [起始时间 时段长]”，实际为%d项", len(fields))
+	}
+
+	return series, nrows, ncols, nil
+}
+
+// setTimes 由各行时间戳确定起始时间及时段长，检查时间是否等间隔连续，并与首行给出的时间一致
+func (s *Series) setTimes(times []time.Time) error {
+	if len(times) < 2 && s.Step == 0 {
+		return fmt.Errorf("只有一个时段时须在首行给出时段长")
+	}
+	step := s.Step
+	if step == 0 {
+		step = times[1].Sub(times[0])
+	}
+	if !s.Start.IsZero() && !s.Start.Equal(times[0]) {
+		return fmt.Errorf("首行起始时间%s与首个时间戳%s不一致", FormatTime(s.Start), FormatTime(times[0]))
+	}
+	for i := 1; i < len(times); i++ {
+		if d := times[i].Sub(times[i-1]); d != step {
+			return fmt.Errorf("第%d个时段%s与上一时段间隔%v，应为%v，存在缺失或错位记录",
+				i+1, FormatTime(times[i]), d, step)
+		}
+	}
+	if step <= 0 {
+		return fmt.Errorf("时段长应大于0")
+	}
+
+	s.Start = times[0]
+	s.Step = step
+	return nil
+}
+
+// Slice 返回时间在[from, to)内的子序列，序列须带有时间信息
+func (s *Series) Slice(from, to time.Time) *Series {
+	i := min(s.Len(), max(0, int(from.Sub(s.Start)/s.Step)))
+	j := min(s.Len(), int(to.Sub(s.Start)/s.Step))
+	if j < i {
+		j = i
+	}
+	return &Series{Start: s.Time(i), Step: s.Step, Values: s.Values[i:j]}
+}
+
+// AlignTo 将序列按时间对齐到另一个序列的各时段，没有对应记录的时段为缺测
+// 两序列须带有时间信息，时段长相同且时间错位为时段长的整数倍
+func (s *Series) AlignTo(ref *Series) (*Series, error) {
+	if s.Step != ref.Step {
+		return nil, fmt.Errorf("时段长%v与%v不一致", s.Step, ref.Step)
+	}
+	offset := s.Start.Sub(ref.Start)
+	if offset%s.Step != 0 {
+		return nil, fmt.Errorf("起始时间%s与%s错位，不是时段长的整数倍", FormatTime(s.Start), FormatTime(ref.Start))
+	}
+	shift := int(offset / s.Step)
+
+	ncols := 1
+	if s.Len() > 0 {
+		ncols = len(s.Values[0])
+	}
+	aligned := &Series{Start: ref.Start, Step: ref.Step, Values: make([][]float64, ref.Len())}
+	for i := range aligned.Values {
+		aligned.Values[i] = make([]float64, ncols)
+		k := i - shift
+		for c := 0; c < ncols; c++ {
+			if k >= 0 && k < s.Len() {
+				aligned.Values[i][c] = s.Values[k][c]
+			} else {
+				aligned.Values[i][c] = math.NaN()
+			}
+		}
+	}
+	return aligned, nil
+}
+
+// ReadOutput 读取WriteFile输出的流量过程，每行为一个数值，或“时间戳 数值”
+func ReadOutput(fileName string) (*Series, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	series := &Series{}
+	var times []time.Time
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(stripComment(scanner.Text()))
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) == 2 {
+			t, err := ParseTime(fields[0])
+			if err != nil {
+				return nil, &ParseError{File: fileName, Line: line, Column: 1, Err: err}
+			}
+			times = append(times, t)
+		}
+		value, err := ParseValue(fields[len(fields)-1])
+		if err != nil {
+			return nil, &ParseError{File: fileName, Line: line, Column: len(fields), Err: err}
+		}
+		series.Values = append(series.Values, []float64{value})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, &ParseError{File: fileName, Err: err}
+	}
+
+	if len(times) > 0 {
+		if len(times) != series.Len() {
+			return nil, &ParseError{File: fileName, Err: fmt.Errorf("部分行缺少时间戳")}
+		}
+		if err := series.setTimes(times); err != nil {
+			return nil, &ParseError{File: fileName, Err: err}
+		}
+	}
+
+	return series, nil
+}
+
+// ReadTimeFile 读取time.txt，首行为时段长（小时数或带单位的时长），第二行为可选的起始时间
+// 文件不存在或为空时返回零值
+func ReadTimeFile(fileName string) (time.Time, time.Duration, error) {
+	file, err := os.Open(fileName)
+	if os.IsNotExist(err) {
+		return time.Time{}, 0, nil
+	}
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	defer file.Close()
+
+	var start time.Time
+	var step time.Duration
+	scanner := bufio.NewScanner(file)
+	line, n := 0, 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(stripComment(scanner.Text()))
+		if text == "" {
+			continue
+		}
+		n++
+		switch n {
+		case 1:
+			if step, err = ParseStep(text); err != nil {
+				return time.Time{}, 0, &ParseError{File: fileName, Line: line, Err: err}
+			}
+		case 2:
+			if start, err = ParseTime(text); err != nil {
+				return time.Time{}, 0, &ParseError{File: fileName, Line: line, Err: err}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return time.Time{}, 0, &ParseError{File: fileName, Err: err}
+	}
+
+	return start, step, nil
+}
@@ -0,0 +1,301 @@
+package Watershed
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadSeries(t *testing.T) {
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	nan := math.NaN()
+	tests := []struct {
+		name    string
+		content string
+		start   time.Time
+		step    time.Duration
+		values  [][]float64
+	}{
+		{"无时间信息", "2 2\n1 2\n3 4\n", time.Time{}, 0, [][]float64{{1, 2}, {3, 4}}},
+		{"首行给出时间", "2 1 2000-01-01 6h // 注释\n1\n2\n", start, 6 * time.Hour, [][]float64{{1}, {2}}},
+		{"首列为时间戳", "2 1\n2000-01-01T00:00\t1\n2000-01-02T00:00\t2\n", start, 24 * time.Hour, [][]float64{{1}, {2}}},
+		{"首行与时间戳均给出", "1 1 2000-01-01 24\n2000-01-01 5\n", start, 24 * time.Hour, [][]float64{{5}}},
+		{"制表符分隔的空单元格为缺测", "2 2\n1\t\n\t4\t\n", time.Time{}, 0, [][]float64{{1, nan}, {nan, 4}}},
+		{"缺测标记", "2 2\nNaN -9999\n1 2\n", time.Time{}, 0, [][]float64{{nan, nan}, {1, 2}}},
+		{"单列空行为缺测", "2 1\n\n3\n", time.Time{}, 0, [][]float64{{nan}, {3}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ReadSeries(writeFile(t, "P.txt", tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !s.Start.Equal(tt.start) || s.Step != tt.step {
+				t.Errorf("起始时间%v、时段长%v，应为%v、%v", s.Start, s.Step, tt.start, tt.step)
+			}
+			if !equalValues(s.Values, tt.values) {
+				t.Errorf("数据为%v，应为%v", s.Values, tt.values)
+			}
+		})
+	}
+}
+
+func TestReadSeriesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     string // 错误信息中文件名之后的部分
+	}{
+		{"空文件", "", ":1: 缺少“行数 列数”首行"},
+		{"首行只有一项", "2\n", ":1: 首行应为“行数 列数 [起始时间 时段长]”"},
+		{"行数无效", "a 1\n", ":1: 行数无效"},
+		{"列数为0", "1 0\n", ":1: 行数1或列数0无效"},
+		{"首行有3项", "1 1 2000-01-01\n1\n", ":1: 首行应为“行数 列数 [起始时间 时段长]”，实际为3项"},
+		{"时段长无效", "1 1 2000-01-01 -6\n1\n", ":1: 时段长应大于0"},
+		{"行数不足", "3 1\n1\n2\n", ":4: 应有3行数据，实际为2行"},
+		{"列数不符", "1 2\n1 2 3\n", ":2: 应有2列，实际为3列"},
+		{"数值无效", "1 2\n1 x\n", ":2:2: "},
+		{"时间戳列后的数值无效", "1 1 2000-01-01 24\n2000-01-01 x\n", ":2:2: "},
+		{"时间戳无效", "2 1\n2000-01-01 1\n2000-13-01 2\n", ":3:1: 无法解析的时间"},
+		{"时间不连续", "3 1\n2000-01-01 1\n2000-01-02 2\n2000-01-04 3\n", ": 第3个时段2000-01-04T00:00与上一时段间隔"},
+		{"起始时间不一致", "1 1 2000-01-02 24\n2000-01-01 1\n", ": 首行起始时间2000-01-02T00:00与首个时间戳2000-01-01T00:00不一致"},
+		{"单个时段缺少时段长", "1 1\n2000-01-01 1\n", ": 只有一个时段时须在首行给出时段长"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName := writeFile(t, "P.txt", tt.content)
+			_, err := ReadSeries(fileName)
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("错误为%v，应为ParseError", err)
+			}
+			if !strings.HasPrefix(err.Error(), fileName+tt.err) {
+				t.Errorf("错误为%q，应以%q开头", err.Error(), fileName+tt.err)
+			}
+		})
+	}
+}
+
+func TestAlignTo(t *testing.T) {
+	day := 24 * time.Hour
+	ref := &Series{Start: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), Step: day, Values: make([][]float64, 4)}
+	s := &Series{Start: ref.Start.Add(day), Step: day, Values: [][]float64{{1}, {2}, {3}, {4}}}
+	aligned, err := s.AlignTo(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nan := math.NaN()
+	if want := [][]float64{{nan}, {1}, {2}, {3}}; !equalValues(aligned.Values, want) {
+		t.Errorf("对齐后为%v，应为%v", aligned.Values, want)
+	}
+
+	s.Start = ref.Start.Add(time.Hour)
+	if _, err := s.AlignTo(ref); err == nil {
+		t.Error("时间错位不是时段长的整数倍时应返回错误")
+	}
+	s.Start, s.Step = ref.Start, time.Hour
+	if _, err := s.AlignTo(ref); err == nil {
+		t.Error("时段长不一致时应返回错误")
+	}
+}
+
+func TestSlice(t *testing.T) {
+	day := 24 * time.Hour
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := &Series{Start: start, Step: day, Values: [][]float64{{1}, {2}, {3}, {4}}}
+	sub := s.Slice(start.Add(day), start.Add(3*day))
+	if !sub.Start.Equal(start.Add(day)) || !reflect.DeepEqual(sub.Values, [][]float64{{2}, {3}}) {
+		t.Errorf("子序列从%v开始，数据为%v", sub.Start, sub.Values)
+	}
+	if sub := s.Slice(start.Add(10*day), start.Add(20*day)); sub.Len() != 0 {
+		t.Errorf("超出范围的子序列有%d个时段", sub.Len())
+	}
+}
+
+// WriteFile、WriteSeries的输出应能分别由ReadOutput、ReadSeries读回
+func TestWriteRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	io := &IO{Nrows: 3, Start: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), Step: 6 * time.Hour, MQ: []float64{1.5, 2.25, 0}}
+
+	fileName := filepath.Join(dir, "Q.txt")
+	if err := io.WriteFile(fileName); err != nil {
+		t.Fatal(err)
+	}
+	q, err := ReadOutput(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !q.Start.Equal(io.Start) || q.Step != io.Step || !reflect.DeepEqual(q.Column(0), io.MQ) {
+		t.Errorf("读回的流量过程为%v，从%v开始，时段长%v", q.Column(0), q.Start, q.Step)
+	}
+
+	fileName = filepath.Join(dir, "gauges.txt")
+	series := [][]float64{{1, 2, 3}, {4, math.NaN(), 6}}
+	if err := io.WriteSeries(fileName, []string{"G1", "G2"}, series); err != nil {
+		t.Fatal(err)
+	}
+	s, err := ReadSeries(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Start.Equal(io.Start) || s.Step != io.Step || !equalValues([][]float64{s.Column(0), s.Column(1)}, series) {
+		t.Errorf("读回的多列流量过程为%v", s.Values)
+	}
+
+	if err := io.WriteFile(filepath.Join(dir, "missing", "Q.txt")); err == nil {
+		t.Error("目录不存在时应返回错误")
+	}
+}
+
+func TestReadOutputErrors(t *testing.T) {
+	for _, content := range []string{"2000-01-01 1\n2\n", "2000-01-01 1\n2000-01-03 2\n2000-01-04 3\n", "x\n"} {
+		if _, err := ReadOutput(writeFile(t, "Q.txt", content)); err == nil {
+			t.Errorf("%q应无法读取", content)
+		}
+	}
+}
+
+// equalValues 比较两个二维数组，NaN视为相等
+func equalValues(a, b [][]float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if len(a[i]) != len(b[i]) {
+			return false
+		}
+		for j := range a[i] {
+			if a[i][j] != b[i][j] && !(math.IsNaN(a[i][j]) && math.IsNaN(b[i][j])) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestParseStep(t *testing.T) {
+	tests := []struct {
+		token   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"24", 24 * time.Hour, false},
+		{"0.5", 30 * time.Minute, false},
+		{"6h", 6 * time.Hour, false},
+		{"30m", 30 * time.Minute, false},
+		{"0", 0, true},
+		{"-1", 0, true},
+		{"-1h", 0, true},
+		{"day", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseStep(tt.token)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseStep(%q) = %v, %v", tt.token, got, err)
+		}
+	}
+}
+
+func TestReadTimeFile(t *testing.T) {
+	start, step, err := ReadTimeFile(writeFile(t, "time.txt", "6 // 时段长\n\n2000-01-01T06:00\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !start.Equal(time.Date(2000, 1, 1, 6, 0, 0, 0, time.UTC)) || step != 6*time.Hour {
+		t.Errorf("起始时间%v、时段长%v", start, step)
+	}
+
+	start, step, err = ReadTimeFile(filepath.Join(t.TempDir(), "time.txt"))
+	if err != nil || !start.IsZero() || step != 0 {
+		t.Errorf("文件不存在时得到%v、%v、%v，应为零值", start, step, err)
+	}
+
+	for content, want := range map[string]string{"x\n": ":1: 无法解析的时段长", "24\n2000-02-30\n": ":2: 无法解析的时间"} {
+		fileName := writeFile(t, "time.txt", content)
+		if _, _, err := ReadTimeFile(fileName); err == nil || !strings.HasPrefix(err.Error(), fileName+want) {
+			t.Errorf("%q的错误为%v，应以%q开头", content, err, fileName+want)
+		}
+	}
+}
+
+// time.txt给出的时间用于没有时间信息的数据文件，并按时间对齐降雨、蒸发及实测流量
+func TestReadFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		fileName := filepath.Join(dir, name)
+		if err := os.WriteFile(fileName, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return fileName
+	}
+	files := Files{
+		P:        write("P.txt", "4 1\n1\n2\n3\n4\n"),
+		EM:       write("EM.txt", "3 1 2000-01-02 6h\n0.1\n0.2\n0.3\n"),
+		Observed: write("observed_Q.txt", "2 1\n2000-01-02T06:00 10\n2000-01-02T12:00 11\n"),
+		Start:    time.Date(2000, 1, 1, 18, 0, 0, 0, time.UTC),
+		Step:     6 * time.Hour,
+	}
+	var io IO
+	if err := io.ReadFiles(files); err != nil {
+		t.Fatal(err)
+	}
+	nan := math.NaN()
+	if !io.Start.Equal(time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)) || io.Nrows != 3 {
+		t.Errorf("从%v开始，共%d个时段", io.Start, io.Nrows)
+	}
+	if !equalValues(io.Mp, [][]float64{{2}, {3}, {4}}) || !equalValues([][]float64{io.Q}, [][]float64{{nan, 10, 11}}) {
+		t.Errorf("降雨为%v，实测流量为%v", io.Mp, io.Q)
+	}
+
+	files.Step = 24 * time.Hour
+	if err := io.ReadFiles(files); err == nil {
+		t.Error("数据文件时段长与time.txt不一致时应返回错误")
+	}
+}
+
+func TestPeriod(t *testing.T) {
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	newIO := func() *IO {
+		return &IO{Nrows: 4, Start: start, Step: 24 * time.Hour,
+			Mp: [][]float64{{1}, {2}, {3}, {4}}, MEM: [][]float64{{1}, {2}, {3}, {4}}, Q: []float64{1, 2, 3, 4}}
+	}
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     []float64 // 保留的实测流量，nil表示应返回错误
+	}{
+		{"不限制", time.Time{}, time.Time{}, []float64{1, 2, 3, 4}},
+		{"只限制首时段", start.Add(24 * time.Hour), time.Time{}, []float64{2, 3, 4}},
+		{"首时段不在整时段上", start.Add(30 * time.Hour), time.Time{}, []float64{3, 4}},
+		{"末时段含在内", time.Time{}, start.Add(48 * time.Hour), []float64{1, 2, 3}},
+		{"超出数据范围", start.Add(-48 * time.Hour), start.Add(240 * time.Hour), []float64{1, 2, 3, 4}},
+		{"没有时段", start.Add(120 * time.Hour), time.Time{}, nil},
+	}
+	for _, tt := range tests {
+		io := newIO()
+		err := io.Period(tt.from, tt.to)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%s: 应返回错误", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(io.Q, tt.want) || io.Nrows != len(tt.want) || len(io.Mp) != len(tt.want) ||
+			!io.Start.Equal(start.Add(time.Duration(tt.want[0]-1)*24*time.Hour)) {
+			t.Errorf("%s: 保留的实测流量为%v，从%v开始", tt.name, io.Q, io.Start)
+		}
+	}
+
+	io := &IO{Nrows: 1, Mp: [][]float64{{1}}, MEM: [][]float64{{1}}}
+	if err := io.Period(start, time.Time{}); err == nil {
+		t.Error("数据没有时间信息时不能指定计算时段")
+	}
+}
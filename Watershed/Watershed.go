@@ -3,9 +3,12 @@ package Watershed
 import (
 	"bufio"
 	"fmt"
+	"math"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Watershed struct {
@@ -23,9 +26,16 @@ type Watershed struct {
 	EM                     [][]float64
 }
 
+// ReadFromFile 读取数据目录下的watershed.txt
 func (ws *Watershed) ReadFromFile(strPath string) error {
+	return ws.ReadFile(strPath + "watershed.txt")
+}
+
+// ReadFile 读取流域信息文件
+func (ws *Watershed) ReadFile(fileName string) error {
+
 	// 打开文件
-	file, err := os.Open(strPath + "watershed.txt")
+	file, err := os.Open(fileName)
 	if err != nil {
 		return fmt.Errorf("failed to open file: %v", err)
 	}
@@ -35,60 +45,154 @@ func (ws *Watershed) ReadFromFile(strPath string) error {
 	scanner := bufio.NewScanner(file)
 
 	for scanner.Scan() {
-		line := scanner.Text()
+		line := strings.TrimSpace(stripComment(scanner.Text()))
 		lines = append(lines, line)
 	}
+	if err := scanner.Err(); err != nil {
+		return &ParseError{File: fileName, Err: err}
+	}
 
-	ws.Name = lines[0]
-	ws.Area, _ = strconv.ParseFloat(lines[1], 64)
-	ws.NumRainfallStation, _ = strconv.Atoi(lines[2])
-	ws.NumEvaporationStation, _ = strconv.Atoi(lines[3])
-	ws.NumSubWatershed, _ = strconv.Atoi(lines[4])
+	// line 返回第i行（从0开始），行数不足时返回错误
+	line := func(i int) (string, error) {
+		if i >= len(lines) {
+			return "", &ParseError{File: fileName, Line: i + 1, Err: fmt.Errorf("文件行数不足")}
+		}
+		return lines[i], nil
+	}
+	// row 解析第i行的n个数值
+	row := func(i, n int) ([]float64, error) {
+		text, err := line(i)
+		if err != nil {
+			return nil, err
+		}
+		fields := strings.Fields(text)
+		if len(fields) != n {
+			return nil, &ParseError{File: fileName, Line: i + 1, Err: fmt.Errorf("应有%d列，实际为%d列", n, len(fields))}
+		}
+		values := make([]float64, n)
+		for j, field := range fields {
+			if values[j], err = strconv.ParseFloat(field, 64); err != nil {
+				return nil, &ParseError{File: fileName, Line: i + 1, Column: j + 1, Err: err}
+			}
+		}
+		return values, nil
+	}
+	// count 解析第i行的正整数
+	count := func(i int) (int, error) {
+		text, err := line(i)
+		if err != nil {
+			return 0, err
+		}
+		n, err := strconv.Atoi(text)
+		if err != nil {
+			return 0, &ParseError{File: fileName, Line: i + 1, Column: 1, Err: err}
+		}
+		if n <= 0 {
+			return 0, &ParseError{File: fileName, Line: i + 1, Column: 1, Err: fmt.Errorf("个数应大于0，实际为%d", n)}
+		}
+		return n, nil
+	}
 
-	// 读取子流域面积
-	ws.AreaSubWatershed = make([]float64, ws.NumSubWatershed)
-	AreaSubWatershed := strings.Split(lines[5], "\t")
-	for i := 0; i < len(AreaSubWatershed); i++ {
-		ws.AreaSubWatershed[i], _ = strconv.ParseFloat(AreaSubWatershed[i], 64)
+	if ws.Name, err = line(0); err != nil {
+		return err
+	}
+	area, err := row(1, 1)
+	if err != nil {
+		return err
+	}
+	ws.Area = area[0]
+	if ws.NumRainfallStation, err = count(2); err != nil {
+		return err
+	}
+	if ws.NumEvaporationStation, err = count(3); err != nil {
+		return err
+	}
+	if ws.NumSubWatershed, err = count(4); err != nil {
+		return err
 	}
 
-	// 读取雨量站权重
-	ws.RateRainfallStation = make([][]float64, ws.NumSubWatershed)
-	for i := 0; i < ws.NumSubWatershed; i++ {
-		rainfallStationLine := strings.Split(lines[6+i], "\t")
-		ws.RateRainfallStation[i] = make([]float64, ws.NumRainfallStation)
-		for j := 0; j < ws.NumRainfallStation; j++ {
-			ws.RateRainfallStation[i][j], _ = strconv.ParseFloat(rainfallStationLine[j], 64)
+	// 读取子流域面积，个数须与单元流域个数一致
+	if ws.AreaSubWatershed, err = row(5, ws.NumSubWatershed); err != nil {
+		return err
+	}
+	for i, f := range ws.AreaSubWatershed {
+		if f <= 0 {
+			return &ParseError{File: fileName, Line: 6, Column: i + 1, Err: fmt.Errorf("单元流域面积应大于0，实际为%g", f)}
 		}
 	}
 
-	// 读取蒸发站权重
-	ws.RateEvaporationStation = make([][]float64, ws.NumSubWatershed)
-	for i := 0; i < ws.NumSubWatershed; i++ {
-		evaporationStationLine := strings.Split(lines[6+ws.NumSubWatershed+i], "\t") // 计算正确的起始行位置
-		ws.RateEvaporationStation[i] = make([]float64, ws.NumEvaporationStation)
-		for j := 0; j < ws.NumEvaporationStation; j++ {
-			ws.RateEvaporationStation[i][j], _ = strconv.ParseFloat(evaporationStationLine[j], 64)
+	// 读取雨量站、蒸发站权重，每个单元流域的权重之和应为1
+	readRates := func(start, n int) ([][]float64, error) {
+		rates := make([][]float64, ws.NumSubWatershed)
+		for i := 0; i < ws.NumSubWatershed; i++ {
+			if rates[i], err = row(start+i, n); err != nil {
+				return nil, err
+			}
+			sum := 0.0
+			for _, rate := range rates[i] {
+				sum += rate
+			}
+			if math.Abs(sum-1) > RateTolerance {
+				return nil, &ParseError{File: fileName, Line: start + i + 1, Err: fmt.Errorf("站点权重之和应为1，实际为%g", sum)}
+			}
 		}
+		return rates, nil
 	}
-
-	// 读取雨量站名称
-	ws.NameRainfallStation = make([]string, ws.NumRainfallStation)
-	stationName := strings.Split(lines[6+ws.NumSubWatershed*2], " ")
-	for i := 0; i < len(stationName); i++ {
-		ws.NameRainfallStation[i] = stationName[i] // 计算雨量站名称的起始行位置
+	if ws.RateRainfallStation, err = readRates(6, ws.NumRainfallStation); err != nil {
+		return err
+	}
+	if ws.RateEvaporationStation, err = readRates(6+ws.NumSubWatershed, ws.NumEvaporationStation); err != nil {
+		return err
 	}
 
-	// 读取蒸发站名称
-	ws.NameEvaporationStation = make([]string, ws.NumEvaporationStation)
-	EvaporationName := strings.Split(lines[6+ws.NumSubWatershed*2+1], "\t")
-	for i := 0; i < len(EvaporationName); i++ {
-		ws.NameEvaporationStation[i] = EvaporationName[i] // 计算蒸发站名称的起始行位置
+	// 读取雨量站、蒸发站名称
+	readNames := func(i, n int) ([]string, error) {
+		text, err := line(i)
+		if err != nil {
+			return nil, err
+		}
+		names := strings.Fields(text)
+		if len(names) != n {
+			return nil, &ParseError{File: fileName, Line: i + 1, Err: fmt.Errorf("应有%d个站名，实际为%d个", n, len(names))}
+		}
+		return names, nil
+	}
+	if ws.NameRainfallStation, err = readNames(6+ws.NumSubWatershed*2, ws.NumRainfallStation); err != nil {
+		return err
+	}
+	if ws.NameEvaporationStation, err = readNames(6+ws.NumSubWatershed*2+1, ws.NumEvaporationStation); err != nil {
+		return err
 	}
 
 	return nil
 }
 
+// 站点权重之和与1的允许偏差
+const RateTolerance = 0.01
+
+// ParseError 数据文件解析错误，Line、Column从1开始，为0时表示不确定
+type ParseError struct {
+	File   string // 文件名
+	Line   int    // 行号
+	Column int    // 列号
+	Err    error  // 具体错误
+}
+
+func (e *ParseError) Error() string {
+	switch {
+	case e.Line > 0 && e.Column > 0:
+		return fmt.Sprintf("%s:%d:%d: %v", e.File, e.Line, e.Column, e.Err)
+	case e.Line > 0:
+		return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+	default:
+		return fmt.Sprintf("%s: %v", e.File, e.Err)
+	}
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
 func (w *Watershed) SetValues(name string, area float64, numRainfallStation, numEvaporationStation, numSubWatershed int, areaSubWatershed []float64, rateRainfallStation, rateEvaporationStation [][]float64, nameRainfallStation, nameEvaporationStation []string, P, EM [][]float64) {
 	w.Name = name
 	w.Area = area
@@ -104,7 +208,12 @@ func (w *Watershed) SetValues(name string, area float64, numRainfallStation, num
 	w.EM = EM
 }
 
-func (w *Watershed) Calculate(io *IO) {
+// Calculate 由各站点降雨、蒸发按权重计算各单元流域逐时段降雨、蒸发，数据与流域信息不符时返回错误
+func (w *Watershed) Calculate(io *IO) error {
+	if err := w.CheckIO(io); err != nil {
+		return err
+	}
+
 	nrows := io.Nrows          // 记录条数，即时段数
 	ncols := w.NumSubWatershed // 单元流域个数
 
@@ -129,6 +238,34 @@ func (w *Watershed) Calculate(io *IO) {
 			}
 		}
 	}
+
+	return nil
+}
+
+// CheckIO 检查降雨、蒸发数据的站点数与流域信息是否一致，以及是否仍有未插补的缺测
+func (w *Watershed) CheckIO(io *IO) error {
+	if len(io.Mp) != io.Nrows || len(io.MEM) != io.Nrows {
+		return fmt.Errorf("降雨记录%d条、蒸发记录%d条，与时段数%d不一致", len(io.Mp), len(io.MEM), io.Nrows)
+	}
+	for r := 0; r < io.Nrows; r++ {
+		if len(io.Mp[r]) != w.NumRainfallStation {
+			return fmt.Errorf("第%d时段降雨数据有%d个站点，流域信息中雨量站为%d个", r+1, len(io.Mp[r]), w.NumRainfallStation)
+		}
+		if len(io.MEM[r]) != w.NumEvaporationStation {
+			return fmt.Errorf("第%d时段蒸发数据有%d个站点，流域信息中蒸发站为%d个", r+1, len(io.MEM[r]), w.NumEvaporationStation)
+		}
+		for _, v := range io.Mp[r] {
+			if math.IsNaN(v) {
+				return fmt.Errorf("第%d时段降雨存在未插补的缺测", r+1)
+			}
+		}
+		for _, v := range io.MEM[r] {
+			if math.IsNaN(v) {
+				return fmt.Errorf("第%d时段蒸发存在未插补的缺测", r+1)
+			}
+		}
+	}
+	return nil
 }
 
 func (w *Watershed) GetP(nt, nw int) float64 {
@@ -178,100 +315,399 @@ func (w *Watershed) Destroy() {
 
 type IO struct {
 	MQ    []float64 // 流量
-	Q     []float64 // 观测流量
+	Q     []float64 // 观测流量，缺测为NaN
 	Nrows int
 	Ncols int
 	Mp    [][]float64 // 降雨
 	MEM   [][]float64 // 蒸发
+
+	Start time.Time     // 首时段时间，零值表示数据没有时间信息
+	Step  time.Duration // 时段长，来自数据文件或time.txt
+
+	FillMethod string // 降雨、蒸发缺测插补方法：zero、linear、nearest，默认为zero
+	NumFilled  int    // 降雨、蒸发中被插补的缺测值个数
 }
 
-func (io *IO) ReadFromFile(strPath string) {
-	// 读取降雨数据
-	file, err := os.Open(strPath + "P.txt")
+// 缺测标记，数据文件中的空值、NaN及-9999均视为缺测，读入后以NaN表示
+const MissingValue = -9999.0
+
+// 降雨、蒸发缺测插补方法
+const (
+	FillZero    = "zero"    // 缺测置为0
+	FillLinear  = "linear"  // 按前后有效值线性插值，首尾缺测取最近的有效值
+	FillNearest = "nearest" // 取同时段与该站相关系数最大的有效站点的值，均缺测时按线性插值
+)
+
+// ParseValue 解析数据文件中的一个数值，缺测返回NaN
+func ParseValue(token string) (float64, error) {
+	token = strings.TrimSpace(token)
+	if token == "" || strings.EqualFold(token, "NaN") {
+		return math.NaN(), nil
+	}
+	value, err := strconv.ParseFloat(token, 64)
 	if err != nil {
-		fmt.Println("Error opening file:", err)
-		return
+		return math.NaN(), err
 	}
-	defer file.Close()
+	if value == MissingValue {
+		return math.NaN(), nil
+	}
+	return value, nil
+}
 
-	scanner := bufio.NewScanner(file)
-	if scanner.Scan() {
-		fmt.Sscanf(scanner.Text(), "%d %d", &io.Nrows, &io.Ncols)
+// stripComment 去掉行中“//”之后的注释
+func stripComment(line string) string {
+	if i := strings.Index(line, "//"); i >= 0 {
+		return line[:i]
 	}
-	io.Mp = make([][]float64, io.Nrows)
-	for r := 0; r < io.Nrows; r++ {
-		io.Mp[r] = make([]float64, io.Ncols)
-		mp := make([]string, 0)
-		if scanner.Scan() {
-			mp = strings.Split(scanner.Text(), "\t")
+	return line
+}
+
+// ReadFromFile 读取降雨P.txt、蒸发EM.txt及可选的实测流量observed_Q.txt、时段信息time.txt，
+// 并插补降雨、蒸发中的缺测
+// 各数据文件格式见ReadSeries；降雨、蒸发均带有时间时取两者重叠的时段，实测流量按时间对齐到该时段
+func (io *IO) ReadFromFile(strPath string) error {
+	// time.txt给出的起始时间及时段长用于没有时间信息的数据文件
+	start, step, err := ReadTimeFile(strPath + "time.txt")
+	if err != nil {
+		return err
+	}
+
+	files := Files{P: strPath + "P.txt", EM: strPath + "EM.txt", Start: start, Step: step}
+	if _, err := os.Stat(strPath + "observed_Q.txt"); err == nil {
+		files.Observed = strPath + "observed_Q.txt"
+	}
+	return io.ReadFiles(files)
+}
+
+// Files 驱动数据文件
+type Files struct {
+	P        string        // 降雨文件
+	EM       string        // 蒸发文件
+	Observed string        // 实测流量文件，为空时不读取
+	Start    time.Time     // 没有时间信息的数据文件的首时段时间，可为零值
+	Step     time.Duration // 时段长，数据文件带有时间时须与之一致，可为0
+}
+
+// ReadFiles 读取降雨、蒸发及可选的实测流量，并插补降雨、蒸发中的缺测，对齐规则同ReadFromFile
+func (io *IO) ReadFiles(files Files) error {
+	p, err := ReadSeries(files.P)
+	if err != nil {
+		return err
+	}
+	em, err := ReadSeries(files.EM)
+	if err != nil {
+		return err
+	}
+
+	start, step := files.Start, files.Step
+	for _, series := range []*Series{p, em} {
+		if step > 0 && series.HasTime() && series.Step != step {
+			return fmt.Errorf("数据文件时段长%v与给定的时段长%v不一致", series.Step, step)
+		}
+		if !series.HasTime() && !start.IsZero() && step > 0 {
+			series.Start, series.Step = start, step
+		}
+	}
+
+	// 对齐降雨、蒸发
+	switch {
+	case p.HasTime() && em.HasTime():
+		if p.Step != em.Step {
+			return fmt.Errorf("降雨时段长%v与蒸发时段长%v不一致", p.Step, em.Step)
+		}
+		if em.Start.Sub(p.Start)%p.Step != 0 {
+			return fmt.Errorf("蒸发起始时间%s与降雨起始时间%s错位", FormatTime(em.Start), FormatTime(p.Start))
+		}
+		from, to := p.Start, p.End()
+		if em.Start.After(from) {
+			from = em.Start
+		}
+		if em.End().Before(to) {
+			to = em.End()
+		}
+		if !to.After(from) {
+			return fmt.Errorf("降雨与蒸发没有重叠的时段")
 		}
-		for c := 0; c < io.Ncols; c++ {
-			io.Mp[r][c], _ = strconv.ParseFloat(strings.TrimSpace(mp[c]), 64)
+		p, em = p.Slice(from, to), em.Slice(from, to)
+	case p.HasTime() || em.HasTime():
+		if p.Len() != em.Len() {
+			return &ParseError{File: files.EM, Line: 1, Err: fmt.Errorf("蒸发记录%d条与降雨记录%d条不一致", em.Len(), p.Len())}
 		}
+		if em.HasTime() {
+			p.Start, p.Step = em.Start, em.Step
+		}
+	default:
+		if p.Len() != em.Len() {
+			return &ParseError{File: files.EM, Line: 1, Err: fmt.Errorf("蒸发记录%d条与降雨记录%d条不一致", em.Len(), p.Len())}
+		}
+	}
+
+	io.Mp = p.Values
+	io.MEM = em.Values
+	io.Nrows = p.Len()
+	io.Ncols = 0
+	if io.Nrows > 0 {
+		io.Ncols = len(io.Mp[0])
+	}
+	io.Start = p.Start
+	io.Step = p.Step
+	if io.Step == 0 {
+		io.Step = step
 	}
 
-	// 读取蒸发数据
-	file, err = os.Open(strPath + "EM.txt")
+	// 插补降雨、蒸发中的缺测值
+	if err := io.FillGaps(); err != nil {
+		return err
+	}
+
+	// 读取观测流量数据，未给出时不读取
+	fileName := files.Observed
+	if fileName == "" {
+		io.Q = nil
+		return nil
+	}
+	q, err := ReadSeries(fileName)
 	if err != nil {
-		fmt.Println("Error opening file:", err)
-		return
+		return err
 	}
-	defer file.Close()
+	if q.HasTime() && p.HasTime() {
+		if q, err = q.AlignTo(p); err != nil {
+			return &ParseError{File: fileName, Err: err}
+		}
+	} else if q.Len() != io.Nrows {
+		return &ParseError{File: fileName, Line: 1, Err: fmt.Errorf("实测流量记录%d条与降雨记录%d条不一致", q.Len(), io.Nrows)}
+	}
+	io.Q = q.Column(0)
 
-	scanner = bufio.NewScanner(file)
-	if scanner.Scan() {
-		fmt.Sscanf(scanner.Text(), "%d %d", &io.Nrows, &io.Ncols)
+	return nil
+}
+
+// HasTime 降雨、蒸发数据是否带有时间信息
+func (io *IO) HasTime() bool {
+	return !io.Start.IsZero() && io.Step > 0
+}
+
+// Time 返回第t个时段的时间
+func (io *IO) Time(t int) time.Time {
+	return io.Start.Add(time.Duration(t) * io.Step)
+}
+
+// Period 只保留from至to（含）之间的时段，from或to为零值时不限制该端，数据须带有时间信息
+func (io *IO) Period(from, to time.Time) error {
+	if from.IsZero() && to.IsZero() {
+		return nil
 	}
-	io.MEM = make([][]float64, io.Nrows)
-	for r := 0; r < io.Nrows; r++ {
-		io.MEM[r] = make([]float64, io.Ncols)
-		for c := 0; c < io.Ncols; c++ {
-			if scanner.Scan() {
-				io.MEM[r][c], _ = strconv.ParseFloat(scanner.Text(), 64)
+	if !io.HasTime() {
+		return fmt.Errorf("数据没有时间信息，不能指定计算时段")
+	}
+
+	i, j := 0, io.Nrows
+	if !from.IsZero() {
+		i = max(i, int((from.Sub(io.Start)+io.Step-1)/io.Step))
+	}
+	if !to.IsZero() {
+		j = min(j, int(to.Sub(io.Start)/io.Step)+1)
+	}
+	if j <= i {
+		return fmt.Errorf("数据%s至%s中没有%s至%s的时段", FormatTime(io.Start), FormatTime(io.Time(io.Nrows-1)), FormatTime(from), FormatTime(to))
+	}
+
+	io.Mp, io.MEM = io.Mp[i:j], io.MEM[i:j]
+	if io.Q != nil {
+		io.Q = io.Q[i:j]
+	}
+	io.Start = io.Time(i)
+	io.Nrows = j - i
+	return nil
+}
+
+// FillGaps 按FillMethod插补降雨、蒸发中的缺测值，实测流量中的缺测保留为NaN
+func (io *IO) FillGaps() error {
+	method := io.FillMethod
+	if method == "" {
+		method = FillZero
+	}
+	if method != FillZero && method != FillLinear && method != FillNearest {
+		return fmt.Errorf("未知的缺测插补方法: %s", method)
+	}
+
+	io.NumFilled = fillGaps(io.Mp, method) + fillGaps(io.MEM, method)
+	return nil
+}
+
+// fillGaps 插补[时段][站点]数据中的缺测值，返回插补个数
+func fillGaps(data [][]float64, method string) int {
+	if len(data) == 0 {
+		return 0
+	}
+	nrows, ncols := len(data), len(data[0])
+
+	// 记录缺测位置，插补时只使用原始有效值
+	missing := make([][]bool, nrows)
+	count := 0
+	for r := range data {
+		missing[r] = make([]bool, ncols)
+		for c := range data[r] {
+			if math.IsNaN(data[r][c]) {
+				missing[r][c] = true
+				count++
 			}
 		}
 	}
-
-	// 读取观测流量数据
-	file, err = os.Open(strPath + "observed_Q.txt")
-	if err != nil {
-		fmt.Println("Error opening observed flow file:", err)
-		return
+	if count == 0 {
+		return 0
 	}
-	defer file.Close()
 
-	scanner = bufio.NewScanner(file)
-	if scanner.Scan() {
-		fmt.Sscanf(scanner.Text(), "%d %d", &io.Nrows, &io.Ncols)
+	switch method {
+	case FillZero:
+		for r := range data {
+			for c := range data[r] {
+				if missing[r][c] {
+					data[r][c] = 0
+				}
+			}
+		}
+	case FillLinear:
+		for c := 0; c < ncols; c++ {
+			fillLinear(data, missing, c)
+		}
+	case FillNearest:
+		// 先按站点间相关系数由同时段有效站点插补，其余按时间线性插值
+		for c := 0; c < ncols; c++ {
+			order := stationOrder(data, missing, c)
+			for r := 0; r < nrows; r++ {
+				if !missing[r][c] {
+					continue
+				}
+				for _, k := range order {
+					if !missing[r][k] {
+						data[r][c] = data[r][k]
+						break
+					}
+				}
+			}
+		}
+		for c := 0; c < ncols; c++ {
+			fillLinear(data, missing, c)
+		}
 	}
 
-	// 初始化观测流量数组
-	io.Q = make([]float64, io.Nrows)
+	return count
+}
 
-	// 读取观测流量数据
-	for i := 0; i < io.Nrows; i++ {
-		if scanner.Scan() {
-			io.Q[i], _ = strconv.ParseFloat(scanner.Text(), 64)
+// fillLinear 对第c个站点中仍为NaN的值按时间线性插值，首尾缺测取最近的有效值，全部缺测时置0
+func fillLinear(data [][]float64, missing [][]bool, c int) {
+	prev := -1 // 上一个有效值所在时段
+	for r := 0; r <= len(data); r++ {
+		if r < len(data) && math.IsNaN(data[r][c]) {
+			continue
+		}
+		for g := prev + 1; g < r; g++ {
+			switch {
+			case prev < 0 && r == len(data):
+				data[g][c] = 0
+			case prev < 0:
+				data[g][c] = data[r][c]
+			case r == len(data):
+				data[g][c] = data[prev][c]
+			default:
+				data[g][c] = data[prev][c] + (data[r][c]-data[prev][c])*float64(g-prev)/float64(r-prev)
+			}
 		}
+		prev = r
 	}
+}
 
+// stationOrder 按与第c个站点的相关系数从大到小返回其他站点，相关系数只用两站均有效的时段计算
+func stationOrder(data [][]float64, missing [][]bool, c int) []int {
+	ncols := len(data[0])
+	type station struct {
+		index int
+		corr  float64
+	}
+	var stations []station
+	for k := 0; k < ncols; k++ {
+		if k == c {
+			continue
+		}
+		var n, sx, sy, sxx, syy, sxy float64
+		for r := range data {
+			if missing[r][c] || missing[r][k] {
+				continue
+			}
+			x, y := data[r][c], data[r][k]
+			n++
+			sx += x
+			sy += y
+			sxx += x * x
+			syy += y * y
+			sxy += x * y
+		}
+		corr := -1.0
+		if den := math.Sqrt((n*sxx - sx*sx) * (n*syy - sy*sy)); n > 1 && den > 0 {
+			corr = (n*sxy - sx*sy) / den
+		}
+		stations = append(stations, station{k, corr})
+	}
+
+	sort.SliceStable(stations, func(i, j int) bool {
+		return stations[i].corr > stations[j].corr
+	})
+
+	order := make([]int, len(stations))
+	for i, st := range stations {
+		order[i] = st.index
+	}
+	return order
 }
 
-func (io *IO) WriteToFile(strPath string) {
+// WriteToFile 输出流域出口断面流量过程到目录strPath下的Q.txt
+func (io *IO) WriteToFile(strPath string) error {
 	// 打开Q.txt输出流域出口断面流量过程，没有该文件则新建
-	file, err := os.Create(strPath + "Q.txt")
+	return io.WriteFile(strPath + "Q.txt")
+}
+
+// WriteFile 输出流域出口断面流量过程到指定文件，没有该文件则新建
+func (io *IO) WriteFile(fileName string) error {
+	file, err := os.Create(fileName)
 	if err != nil {
-		fmt.Println("Error creating file:", err)
-		return
+		return err
 	}
 	defer file.Close()
 
 	writer := bufio.NewWriter(file)
-	// 输出流量过程
+	// 输出流量过程，有时间信息时每行为“时间戳\t流量”
 	for i := 0; i < io.Nrows; i++ {
+		if io.HasTime() {
+			fmt.Fprintf(writer, "%s\t", FormatTime(io.Time(i)))
+		}
 		fmt.Fprintln(writer, io.MQ[i])
 	}
-	writer.Flush()
+	return writer.Flush()
+}
+
+// WriteSeries 输出多列流量过程，首行为“时段数 列数 // 各列名称”，可由ReadSeries读取
+// 有时间信息时每行首列为时间戳，各列以制表符分隔
+func (io *IO) WriteSeries(fileName string, names []string, series [][]float64) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	fmt.Fprintf(writer, "%d %d // %s\n", io.Nrows, len(series), strings.Join(names, " "))
+	for i := 0; i < io.Nrows; i++ {
+		cells := make([]string, 0, len(series)+1)
+		if io.HasTime() {
+			cells = append(cells, FormatTime(io.Time(i)))
+		}
+		for _, values := range series {
+			cells = append(cells, strconv.FormatFloat(values[i], 'g', -1, 64))
+		}
+		fmt.Fprintln(writer, strings.Join(cells, "\t"))
+	}
+	return writer.Flush()
 }
 
 func NewIO() *IO {
@@ -0,0 +1,164 @@
+package Watershed
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFile 在临时目录中写入测试文件，返回文件路径
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	fileName := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(fileName, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return fileName
+}
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		token   string
+		want    float64 // NaN表示缺测
+		wantErr bool
+	}{
+		{"1.5", 1.5, false},
+		{" 0 ", 0, false},
+		{"", math.NaN(), false},
+		{"NaN", math.NaN(), false},
+		{"nan", math.NaN(), false},
+		{"-9999", math.NaN(), false},
+		{"-9999.0", math.NaN(), false},
+		{"abc", math.NaN(), true},
+	}
+	for _, tt := range tests {
+		got, err := ParseValue(tt.token)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseValue(%q)的错误为%v", tt.token, err)
+		}
+		if math.IsNaN(tt.want) != math.IsNaN(got) || !math.IsNaN(got) && got != tt.want {
+			t.Errorf("ParseValue(%q) = %g，应为%g", tt.token, got, tt.want)
+		}
+	}
+}
+
+func TestFillGaps(t *testing.T) {
+	nan := math.NaN()
+	// 两站相关，第1站缺测两个时段，第2站首时段缺测
+	data := func() [][]float64 {
+		return [][]float64{{1, nan}, {nan, 4}, {nan, 6}, {4, 8}, {5, 10}}
+	}
+	tests := []struct {
+		method string
+		want   [][]float64
+	}{
+		{FillZero, [][]float64{{1, 0}, {0, 4}, {0, 6}, {4, 8}, {5, 10}}},
+		{FillLinear, [][]float64{{1, 4}, {2, 4}, {3, 6}, {4, 8}, {5, 10}}},
+		{FillNearest, [][]float64{{1, 1}, {4, 4}, {6, 6}, {4, 8}, {5, 10}}},
+	}
+	for _, tt := range tests {
+		io := &IO{Mp: data(), MEM: data(), FillMethod: tt.method}
+		if err := io.FillGaps(); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(io.Mp, tt.want) || !reflect.DeepEqual(io.MEM, tt.want) {
+			t.Errorf("%s插补结果为%v，应为%v", tt.method, io.Mp, tt.want)
+		}
+		if io.NumFilled != 6 {
+			t.Errorf("%s插补个数为%d，应为6", tt.method, io.NumFilled)
+		}
+	}
+
+	io := &IO{Mp: data(), MEM: data(), FillMethod: "mean"}
+	if err := io.FillGaps(); err == nil {
+		t.Error("未知的插补方法应返回错误")
+	}
+}
+
+// 站点全部缺测时按线性插值置0
+func TestFillLinearAllMissing(t *testing.T) {
+	nan := math.NaN()
+	data := [][]float64{{nan}, {nan}}
+	if n := fillGaps(data, FillLinear); n != 2 || data[0][0] != 0 || data[1][0] != 0 {
+		t.Errorf("插补%d个，结果为%v", n, data)
+	}
+}
+
+// validWatershed 2个雨量站、1个蒸发站、2个单元流域的流域信息文件
+const validWatershed = `示例流域
+100
+2
+1
+2
+40 60
+0.5 0.5
+1 0
+1
+1
+P1 P2
+E1
+`
+
+func TestReadFile(t *testing.T) {
+	ws := &Watershed{}
+	if err := ws.ReadFile(writeFile(t, "watershed.txt", validWatershed)); err != nil {
+		t.Fatal(err)
+	}
+	if ws.NumSubWatershed != 2 || !reflect.DeepEqual(ws.AreaSubWatershed, []float64{40, 60}) ||
+		!reflect.DeepEqual(ws.RateRainfallStation, [][]float64{{0.5, 0.5}, {1, 0}}) ||
+		!reflect.DeepEqual(ws.NameEvaporationStation, []string{"E1"}) {
+		t.Errorf("流域信息为%+v", ws)
+	}
+}
+
+func TestReadFileErrors(t *testing.T) {
+	// replace 将validWatershed的第line行（从1计）替换为text
+	replace := func(line int, text string) string {
+		lines := strings.Split(validWatershed, "\n")
+		lines[line-1] = text
+		return strings.Join(lines, "\n")
+	}
+	tests := []struct {
+		name    string
+		content string
+		line    int
+		column  int
+		err     string // 错误信息中文件名之后的部分
+	}{
+		{"空文件", "", 1, 0, ":1: 文件行数不足"},
+		{"缺少站名行", strings.Join(strings.Split(validWatershed, "\n")[:11], "\n"), 12, 0, ":12: 文件行数不足"},
+		{"面积个数少于单元流域数", replace(6, "40"), 6, 0, ":6: 应有2列，实际为1列"},
+		{"面积个数多于单元流域数", replace(6, "40 60 10"), 6, 0, ":6: 应有2列，实际为3列"},
+		{"面积无效", replace(6, "40 x"), 6, 2, ":6:2: "},
+		{"面积不为正", replace(6, "40 0"), 6, 2, ":6:2: 单元流域面积应大于0，实际为0"},
+		{"单元流域数为0", replace(5, "0"), 5, 1, ":5:1: 个数应大于0，实际为0"},
+		{"雨量站权重之和偏小", replace(7, "0.5 0.48"), 7, 0, ":7: 站点权重之和应为1，实际为0.98"},
+		{"蒸发站权重之和偏大", replace(10, "1.02"), 10, 0, ":10: 站点权重之和应为1，实际为1.02"},
+		{"站名个数不符", replace(11, "P1"), 11, 0, ":11: 应有2个站名，实际为1个"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName := writeFile(t, "watershed.txt", tt.content)
+			err := (&Watershed{}).ReadFile(fileName)
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("错误为%v，应为ParseError", err)
+			}
+			if parseErr.Line != tt.line || parseErr.Column != tt.column {
+				t.Errorf("错误位置为第%d行第%d列，应为第%d行第%d列", parseErr.Line, parseErr.Column, tt.line, tt.column)
+			}
+			if !strings.HasPrefix(err.Error(), fileName+tt.err) {
+				t.Errorf("错误为%q，应以%q开头", err.Error(), fileName+tt.err)
+			}
+		})
+	}
+
+	// 权重之和在允许偏差以内时可以读取
+	if err := (&Watershed{}).ReadFile(writeFile(t, "watershed.txt", replace(7, "0.5 0.495"))); err != nil {
+		t.Errorf("权重之和与1的偏差小于%g时不应返回错误，实际为%v", RateTolerance, err)
+	}
+}
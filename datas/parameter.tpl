@@ -1,20 +0,0 @@
-KC
-UM
-LM
-0.18
-
-WM
-B
-0.01
-
-SM
-1.5
-KG
-KI
-
-CS
-CI
-CG
-CR
-24
-XE
\ No newline at end of file
@@ -1,25 +1,930 @@
 package main
 
 import (
+	"cmp"
+	"flag"
 	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"demo2/Assimilation"
 	"demo2/Calibration" // 使用模块路径而不是相对路径
+	"demo2/Correction"
+	"demo2/Data"
+	"demo2/Forecast"
+	"demo2/Model"
+	"demo2/Network"
+	"demo2/Objective"
+	"demo2/Project"
+	"demo2/Watershed"
 )
 
+const usage = `新安江模型命令行工具
+
+用法:
+  xaj simulate  [选项]   使用参数文件运行新安江模型，输出流域出口断面流量过程
+  xaj calibrate [选项]   使用SCE-UA算法率定模型参数
+  xaj evaluate  [选项]   评价已有模拟流量过程与实测流量的拟合程度
+  xaj forecast  [选项]   从状态快照热启动，按预报降雨情景进行实时洪水预报
+  xaj assimilate [选项]  以集合卡尔曼滤波或粒子滤波同化实测流量
+  xaj convert   [选项]   将数据目录下的传统文本文件转换为项目配置文件
+
+使用 "xaj <命令> -h" 查看各命令的选项
+`
+
 func main() {
 	defer func() {
 		if r := recover(); r != nil {
 			fmt.Printf("程序发生错误: %v\n", r)
+			os.Exit(1)
 		}
 	}()
 
+	if len(os.Args) < 2 {
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "simulate":
+		err = runSimulate(os.Args[2:])
+	case "calibrate":
+		err = runCalibrate(os.Args[2:])
+	case "evaluate":
+		err = runEvaluate(os.Args[2:])
+	case "forecast":
+		err = runForecast(os.Args[2:])
+	case "assimilate":
+		err = runAssimilate(os.Args[2:])
+	case "convert":
+		err = runConvert(os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Printf("未知命令: %s\n\n", os.Args[1])
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Printf("错误: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runSimulate 运行新安江模型并输出流域出口断面流量过程
+func runSimulate(args []string) error {
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	dir := fs.String("dir", ".", "数据目录，包含watershed.txt、P.txt、EM.txt")
+	paramFile := fs.String("param", "", "参数文件，默认为数据目录下的parameter.txt")
+	out := fs.String("out", "", "流量输出文件，默认为数据目录下的Q.txt")
+	initial := fs.Bool("init", true, "数据目录下存在initstate.txt时从中读取各单元流域初始状态")
+	warmup := fs.Int("warmup", 0, "预热期时段数，预热期照常计算并输出")
+	spinup := fs.Int("spinup", 0, "预热期最大重复计算次数，直到预热期末蓄量稳定")
+	fill := fs.String("fill", Watershed.FillZero, "降雨、蒸发缺测插补方法：zero、linear或nearest")
+	units := fs.Bool("units", true, "数据目录下存在unitparameter.txt时从中读取各单元流域参数")
+	network := fs.Bool("network", true, "数据目录下存在network.txt时经河网演算至流域出口")
+	gauges := fs.String("gauges", "", "控制断面流量输出文件，默认为数据目录下的gauges.txt")
+	hotstart := fs.String("hotstart", "", "热启动快照文件，从快照时刻继续计算，不再读取initstate.txt")
+	save := fs.String("save", "", "计算结束时的状态快照输出文件")
+	balance := fs.Float64("balance", -1, "单元流域单时段水量平衡闭合误差容许值（mm），不小于0时核算水量平衡并输出累计水量平衡表")
+	balanceFail := fs.Bool("balance-fail", false, "闭合误差超过容许值时报错，默认只给出警告")
+	saveAt := fs.String("save-at", "", "另外保存快照的时段，逗号分隔的时段数或时间戳，文件名为-save加“_时段数”")
+	config := fs.String("config", "", "项目配置文件，给出时按配置读取输入并忽略-dir，显式给出的其他选项优先于配置")
+	fs.Parse(args)
+
+	var (
+		watershed      *Watershed.Watershed
+		io             *Watershed.IO
+		parameter      *Data.Parameter
+		unitParameters []*Data.Parameter
+		states         []*Data.State
+		net            *Network.Network
+		err            error
+	)
+	if *config != "" {
+		// 按项目配置读取输入，命令行中显式给出的选项优先于配置
+		cfg, err := Project.Read(*config)
+		if err != nil {
+			return err
+		}
+		set := make(map[string]bool)
+		fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+		if set["fill"] {
+			cfg.Fill = *fill
+		}
+		in, err := cfg.Load()
+		if err != nil {
+			return err
+		}
+		watershed, io = in.Watershed, in.IO
+		if set["param"] {
+			parameter, err = readParameter(*paramFile)
+		} else {
+			parameter, err = checkParameter(in.Parameter, *config)
+		}
+		if err != nil {
+			return err
+		}
+		if *units && in.Units != nil {
+			unitParameters = in.Units.Resolve(parameter, nil)
+		}
+		if *initial {
+			states = in.Initial
+		}
+		if *network && in.Network != nil {
+			net = in.Network
+			fmt.Printf("经河网演算，共%d个河段、%d个控制断面\n", len(net.Reaches), len(net.Gauges))
+		}
+		if !set["warmup"] {
+			*warmup = cfg.Warmup
+		}
+		if !set["spinup"] {
+			*spinup = cfg.Spinup
+		}
+		if *out == "" {
+			*out = cfg.Path(cmp.Or(cfg.Output.Q, "Q.txt"))
+		}
+		if *gauges == "" {
+			*gauges = cfg.Path(cmp.Or(cfg.Output.Gauges, "gauges.txt"))
+		}
+		if *save == "" {
+			*save = cfg.Path(cfg.Output.Snapshot)
+		}
+	} else {
+		workPath := dirPath(*dir)
+		if *paramFile == "" {
+			*paramFile = workPath + "parameter.txt"
+		}
+		if *out == "" {
+			*out = workPath + "Q.txt"
+		}
+		if *gauges == "" {
+			*gauges = workPath + "gauges.txt"
+		}
+
+		watershed = &Watershed.Watershed{}
+		if err := watershed.ReadFromFile(workPath); err != nil {
+			return err
+		}
+		io = &Watershed.IO{FillMethod: *fill}
+		if err := io.ReadFromFile(workPath); err != nil {
+			return err
+		}
+		if err := watershed.Calculate(io); err != nil {
+			return err
+		}
+
+		if parameter, err = readParameter(*paramFile); err != nil {
+			return err
+		}
+		if *units {
+			if unitParameters, err = readUnitParameters(workPath, watershed.GetnW(), parameter); err != nil {
+				return err
+			}
+		}
+		if _, err := os.Stat(workPath + Data.InitialStateFile); *initial && err == nil {
+			if states, err = Data.ReadInitialStates(workPath, watershed.GetnW()); err != nil {
+				return err
+			}
+		}
+		if *network {
+			if net, err = readNetwork(workPath); err != nil {
+				return err
+			}
+		}
+	}
+	if io.NumFilled > 0 {
+		fmt.Printf("降雨、蒸发中共插补%d个缺测值\n", io.NumFilled)
+	}
+
+	model, err := Model.NewModel(watershed, parameter)
+	if err != nil {
+		return err
+	}
+	dt := 24.0
+	if io.Step > 0 {
+		dt = io.Step.Hours()
+	}
+	if err := model.SetDt(dt); err != nil {
+		return err
+	}
+	if err := model.SetUnitParameters(unitParameters); err != nil {
+		return err
+	}
+	if states != nil && *hotstart == "" {
+		if err := model.SetInitialStates(states); err != nil {
+			return err
+		}
+	}
+	if err := model.SetNetwork(net); err != nil {
+		return err
+	}
+	if *hotstart != "" {
+		if err := restoreSnapshot(model, *hotstart, io); err != nil {
+			return err
+		}
+	}
+	if *saveAt != "" {
+		if *save == "" {
+			return fmt.Errorf("-save-at须与-save同时使用")
+		}
+		steps, err := snapshotSteps(*saveAt, io)
+		if err != nil {
+			return err
+		}
+		model.SetSnapshotSteps(steps)
+	}
+
+	model.SetWarmup(*warmup)
+	model.SetSpinup(*spinup, 0)
+	model.SetBalance(*balance)
+	result, err := model.Run(io.Nrows)
+	if err != nil {
+		return err
+	}
+	if result.Spinup > 0 {
+		fmt.Printf("预热期重复计算%d次\n", result.Spinup)
+	}
+	if result.Balance != nil {
+		result.Balance.WriteTable(os.Stdout)
+		if err := result.Balance.Err(); err != nil {
+			if *balanceFail {
+				return err
+			}
+			fmt.Printf("警告: %v\n", err)
+		}
+	}
+
+	io.MQ = result.Q
+	if err := io.WriteFile(*out); err != nil {
+		return err
+	}
+	if len(result.Gauges) > 0 {
+		if err := io.WriteSeries(*gauges, result.Gauges, result.Gauge); err != nil {
+			return err
+		}
+		fmt.Printf("控制断面流量过程已输出到: %s\n", *gauges)
+	}
+	if *save != "" {
+		snapshots := append(result.Snapshots, model.Snapshot(io.Nrows))
+		for i, snapshot := range snapshots {
+			fileName := *save
+			if i < len(snapshots)-1 {
+				fileName = fmt.Sprintf("%s_%d%s", strings.TrimSuffix(*save, filepath.Ext(*save)), snapshot.Step, filepath.Ext(*save))
+			}
+			if io.HasTime() {
+				snapshot.Time = Watershed.FormatTime(io.Time(snapshot.Step - 1))
+			}
+			if err := snapshot.WriteFile(fileName); err != nil {
+				return err
+			}
+			fmt.Printf("第%d时段末状态快照已输出到: %s\n", snapshot.Step, fileName)
+		}
+	}
+
+	fmt.Printf("模拟完成，共%d个时段，时段长%gh，流量过程已输出到: %s\n", io.Nrows, dt, *out)
+	return nil
+}
+
+// runCalibrate 使用SCE-UA算法率定模型参数
+func runCalibrate(args []string) error {
+	fs := flag.NewFlagSet("calibrate", flag.ExitOnError)
+	dir := fs.String("dir", ".", "数据目录，包含scein.txt及模型输入文件")
+	inMemory := fs.Bool("inmemory", true, "在内存中计算目标函数，不读写parameter_sce.txt和Q.txt")
+	workers := fs.Int("workers", 1, "并行计算目标函数的协程数，仅内存模式下生效")
+	seed := fs.Int64("seed", 0, "随机数种子，不指定时使用scein.txt中的seed或当前时间")
+	warmup := fs.Int("warmup", 0, "预热期时段数，不指定时使用scein.txt中的warmup")
+	spinup := fs.Int("spinup", 0, "预热期最大重复计算次数，不指定时使用scein.txt中的spinup")
+	objective := fs.String("objective", "", "目标函数，如NSE、KGE或“NSE:0.7,Volume:0.3”，不指定时使用scein.txt中的objective")
+	fill := fs.String("fill", "", "降雨、蒸发缺测插补方法：zero、linear或nearest，不指定时使用scein.txt中的fill")
+	config := fs.String("config", "", "项目配置文件，给出时按配置读取输入及率定设置并忽略-dir，只在内存中计算")
+	fs.Parse(args)
+
 	sceua := Calibration.NewSCEUA()
 
-	workPath := "/Users/baogy/goProject/owner/demo2/datas/"
+	workPath := dirPath(*dir)
+	if *config != "" {
+		workPath = dirPath(filepath.Dir(*config))
+	}
 	fmt.Printf("设置工作目录: %s\n", workPath)
 	sceua.SetFilePath(workPath)
+	sceua.SetInMemory(*inMemory)
+	sceua.SetWorkers(*workers)
+	if *config != "" {
+		cfg, err := Project.Read(*config)
+		if err != nil {
+			return err
+		}
+		if cfg.Calibration == nil {
+			return fmt.Errorf("%s中没有率定设置calibration", *config)
+		}
+		if *fill != "" {
+			cfg.Fill = *fill
+		}
+		in, err := cfg.Load()
+		if err != nil {
+			return err
+		}
+		sceua.SetSettings(cfg.Calibration)
+		// 率定设置中未给出预热期时使用配置中的预热期
+		if cfg.Calibration.Warmup == 0 {
+			sceua.SetWarmup(cfg.Warmup)
+		}
+		if cfg.Calibration.Spinup == 0 {
+			sceua.SetSpinup(cfg.Spinup)
+		}
+		sceua.SetInputs(&Calibration.Inputs{
+			Watershed: in.Watershed,
+			IO:        in.IO,
+			Parameter: in.Parameter,
+			Initial:   in.Initial,
+			Network:   in.Network,
+			Units:     in.Units,
+		})
+		sceua.SetReportFile(cfg.Path(cmp.Or(cfg.Output.Calibration, "sceout.txt")))
+	}
+	if *objective != "" {
+		f, err := Objective.ByName(*objective)
+		if err != nil {
+			return err
+		}
+		sceua.SetObjective(f)
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "seed":
+			sceua.SetSeed(*seed)
+		case "warmup":
+			sceua.SetWarmup(*warmup)
+		case "spinup":
+			sceua.SetSpinup(*spinup)
+		case "fill":
+			sceua.SetFillMethod(*fill)
+		}
+	})
 
 	fmt.Println("开始SCE-UA优化...")
-	sceua.Optimize()
+	if err := sceua.Optimize(); err != nil {
+		return err
+	}
 	fmt.Println("优化完成!")
+	return nil
+}
+
+// runEvaluate 计算模拟流量过程与实测流量过程的拟合指标
+func runEvaluate(args []string) error {
+	fs := flag.NewFlagSet("evaluate", flag.ExitOnError)
+	dir := fs.String("dir", ".", "数据目录")
+	sim := fs.String("sim", "", "模拟流量文件，默认为数据目录下的Q.txt")
+	obs := fs.String("obs", "", "实测流量文件，默认为数据目录下的observed_Q.txt")
+	warmup := fs.Int("warmup", 0, "预热期时段数，前warmup个时段不参与评价")
+	objective := fs.String("objective", "", "额外计算的目标函数，如“NSE:0.7,Volume:0.3”")
+	fs.Parse(args)
+
+	workPath := dirPath(*dir)
+	if *sim == "" {
+		*sim = workPath + "Q.txt"
+	}
+	if *obs == "" {
+		*obs = workPath + "observed_Q.txt"
+	}
+
+	simulatedSeries, err := Watershed.ReadOutput(*sim)
+	if err != nil {
+		return err
+	}
+	measuredSeries, err := Watershed.ReadSeries(*obs)
+	if err != nil {
+		return err
+	}
+
+	// 两者均带有时间时按时间对齐，否则要求逐时段对应
+	if simulatedSeries.HasTime() && measuredSeries.HasTime() {
+		if measuredSeries, err = measuredSeries.AlignTo(simulatedSeries); err != nil {
+			return err
+		}
+	}
+	simulated := simulatedSeries.Column(0)
+	measured := measuredSeries.Column(0)
+	if len(simulated) == 0 || len(simulated) != len(measured) {
+		return fmt.Errorf("模拟值(%d个)与实测值(%d个)数量不一致", len(simulated), len(measured))
+	}
+
+	if *warmup < 0 {
+		return fmt.Errorf("预热期时段数%d不能为负", *warmup)
+	}
+	if *warmup >= len(measured) {
+		return fmt.Errorf("预热期时段数%d不小于总时段数%d", *warmup, len(measured))
+	}
+	simulated = simulated[*warmup:]
+	measured = measured[*warmup:]
+
+	paired, _ := Objective.Paired(simulated, measured)
+	fmt.Printf("时段数: %d，其中缺测%d个\n", len(measured), len(measured)-len(paired))
+	if simulatedSeries.HasTime() {
+		fmt.Printf("评价时段: %s ~ %s\n", Watershed.FormatTime(simulatedSeries.Time(*warmup)),
+			Watershed.FormatTime(simulatedSeries.Time(simulatedSeries.Len()-1)))
+	}
+	fmt.Printf("NSE: %f\n", Objective.NSE(simulated, measured))
+	fmt.Printf("KGE: %f\n", Objective.KGE(simulated, measured))
+	fmt.Printf("LogNSE: %f\n", Objective.LogNSE(simulated, measured))
+	fmt.Printf("RMSE: %f\n", Objective.RMSE(simulated, measured))
+	fmt.Printf("PBIAS: %f%%\n", Objective.PBIAS(simulated, measured))
+	fmt.Printf("洪峰相对误差: %f\n", Objective.PeakError(simulated, measured))
+	fmt.Printf("水量相对误差: %f\n", Objective.VolumeError(simulated, measured))
+	fmt.Printf("流量历时曲线误差: %f\n", Objective.FDCError(simulated, measured))
+	if *objective != "" {
+		f, err := Objective.ByName(*objective)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("目标函数%s: %f\n", Objective.Expression(f), f.Evaluate(simulated, measured))
+	}
+	return nil
+}
+
+// runForecast 从发布时刻之前的状态快照热启动，以实测降雨、蒸发计算至发布时刻后，
+// 按各预报降雨情景计算预见期流量过程，输出洪峰流量、峰现时间及洪量
+func runForecast(args []string) error {
+	fs := flag.NewFlagSet("forecast", flag.ExitOnError)
+	dir := fs.String("dir", ".", "数据目录，包含watershed.txt，可包含快照之后至发布时刻的实测P.txt、EM.txt")
+	paramFile := fs.String("param", "", "参数文件，默认为数据目录下的parameter.txt")
+	hotstart := fs.String("hotstart", "", "发布时刻之前的状态快照文件，必须给出")
+	qpf := fs.String("qpf", "", "逗号分隔的预报降雨文件，格式同P.txt，默认为数据目录下的qpf*.txt")
+	emFile := fs.String("em", "", "预见期蒸发文件，格式同EM.txt，默认取实测期末时段的蒸发")
+	fill := fs.String("fill", Watershed.FillZero, "实测降雨、蒸发缺测插补方法：zero、linear或nearest")
+	out := fs.String("out", "", "预报流量过程输出文件，默认为数据目录下的forecast.txt")
+	summary := fs.String("summary", "", "预报特征值输出文件，默认为数据目录下的forecast_summary.txt")
+	correct := fs.String("correct", "", "实时校正方法：ar（误差自回归）、routing（修正QS、QI、QG及河道状态）或storage（修正自由水蓄量），默认不校正")
+	order := fs.Int("ar-order", 2, "AR误差模型的阶数")
+	window := fs.Int("window", 1, "计算状态修正系数时使用的发布时刻之前的时段数")
+	fs.Parse(args)
+
+	workPath := dirPath(*dir)
+	if *paramFile == "" {
+		*paramFile = workPath + "parameter.txt"
+	}
+	if *out == "" {
+		*out = workPath + "forecast.txt"
+	}
+	if *summary == "" {
+		*summary = workPath + "forecast_summary.txt"
+	}
+	if *hotstart == "" {
+		return fmt.Errorf("须由-hotstart给出状态快照")
+	}
+
+	f := &Forecast.Forecast{
+		Watershed:  &Watershed.Watershed{},
+		Correction: Correction.Options{Method: *correct, Order: *order, Window: *window},
+	}
+	if err := f.Correction.Check(); err != nil {
+		return err
+	}
+	if err := f.Watershed.ReadFromFile(workPath); err != nil {
+		return err
+	}
+	parameter, err := readParameter(*paramFile)
+	if err != nil {
+		return err
+	}
+	f.Parameter = parameter
+
+	// 实测降雨、蒸发，没有P.txt时从快照时刻直接进入预见期
+	f.Observed = &Watershed.IO{FillMethod: *fill}
+	if _, err := os.Stat(workPath + "P.txt"); err == nil {
+		if err := f.Observed.ReadFromFile(workPath); err != nil {
+			return err
+		}
+		if err := f.Watershed.CheckIO(f.Observed); err != nil {
+			return err
+		}
+	}
+	snapshot, err := readSnapshot(*hotstart, f.Observed)
+	if err != nil {
+		return err
+	}
+	f.Snapshot = snapshot
+	if f.Observed.Step == 0 {
+		f.Observed.Step = time.Duration(snapshot.Dt * float64(time.Hour))
+	}
+	if f.Observed.Nrows == 0 && snapshot.Time != "" {
+		t, err := Watershed.ParseTime(snapshot.Time)
+		if err != nil {
+			return err
+		}
+		f.Observed.Start = t.Add(f.Observed.Step)
+	}
+
+	if f.Units, err = readUnitParameters(workPath, f.Watershed.GetnW(), f.Parameter); err != nil {
+		return err
+	}
+	if f.Network, err = readNetwork(workPath); err != nil {
+		return err
+	}
+	if *emFile != "" {
+		em, err := Watershed.ReadSeries(*emFile)
+		if err != nil {
+			return err
+		}
+		f.EM = em.Values
+	}
+
+	// 预报降雨情景
+	files := strings.Split(*qpf, ",")
+	if *qpf == "" {
+		if files, err = filepath.Glob(workPath + "qpf*.txt"); err != nil {
+			return err
+		}
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("数据目录下没有预报降雨文件qpf*.txt，可由-qpf给出")
+	}
+
+	var scenarios []*Forecast.Scenario
+	maxLead := 0
+	for _, file := range files {
+		scenario, err := Forecast.ReadScenario(strings.TrimSpace(file))
+		if err != nil {
+			return err
+		}
+		scenarios = append(scenarios, scenario)
+		maxLead = max(maxLead, len(scenario.P))
+	}
+
+	// 实时校正时，observed_Q.txt带时间且覆盖预见期的部分用于评价校正效果
+	if f.Correction.Method != Correction.None && f.Observed.HasTime() {
+		if f.LeadObserved, err = leadObserved(workPath+"observed_Q.txt", f.Issue(), f.Observed.Step, maxLead); err != nil {
+			return err
+		}
+	}
+
+	var hydrographs []*Forecast.Hydrograph
+	for _, scenario := range scenarios {
+		h, err := f.Run(scenario)
+		if err != nil {
+			return err
+		}
+		hydrographs = append(hydrographs, h)
+	}
+
+	// 输出各情景预见期流量过程，预见期长度不同时较短情景以NaN补齐
+	lead := 0
+	names := make([]string, len(hydrographs))
+	for i, h := range hydrographs {
+		lead = max(lead, len(h.Q))
+		names[i] = h.Scenario
+	}
+	series := make([][]float64, len(hydrographs))
+	for i, h := range hydrographs {
+		series[i] = append(append([]float64(nil), h.Q...), make([]float64, lead-len(h.Q))...)
+		for t := len(h.Q); t < lead; t++ {
+			series[i][t] = math.NaN()
+		}
+	}
+	output := &Watershed.IO{Nrows: lead, Start: f.Issue(), Step: f.Observed.Step}
+	if err := output.WriteSeries(*out, names, series); err != nil {
+		return err
+	}
+
+	file, err := os.Create(*summary)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	w := io.MultiWriter(os.Stdout, file)
+	fmt.Fprintf(w, "预报发布时刻之前实测%d个时段，快照时刻: %s\n", f.Observed.Nrows, snapshot.Time)
+	fmt.Fprintln(w, "情景\t洪峰流量(m3/s)\t峰现时段\t峰现时间\t洪量(万m3)")
+	for _, h := range hydrographs {
+		peakTime := "-"
+		if t := h.PeakTime(); !t.IsZero() {
+			peakTime = Watershed.FormatTime(t)
+		}
+		fmt.Fprintf(w, "%s\t%.3f\t%d\t%s\t%.2f\n", h.Scenario, h.Peak, h.PeakStep, peakTime, h.Volume)
+	}
+	if f.Correction.Method != Correction.None {
+		fmt.Fprintf(w, "实时校正方法: %s\n", f.Correction.Method)
+		fmt.Fprintln(w, "情景\t评价时段\t时段数\t校正前NSE\t校正后NSE\t校正前RMSE\t校正后RMSE\t校正前PBIAS(%)\t校正后PBIAS(%)")
+		for _, h := range hydrographs {
+			switch f.Correction.Method {
+			case Correction.AR:
+				fmt.Fprintf(w, "%s\tAR系数: %v\n", h.Scenario, h.Coef)
+			default:
+				fmt.Fprintf(w, "%s\t状态修正系数: %.4f\n", h.Scenario, h.Ratio)
+			}
+			for _, c := range h.Comparisons {
+				fmt.Fprintf(w, "%s\t%s\t%d\t%.4f\t%.4f\t%.3f\t%.3f\t%.2f\t%.2f\n", h.Scenario, c.Period, c.After.N,
+					c.Before.NSE, c.After.NSE, c.Before.RMSE, c.After.RMSE, c.Before.PBIAS, c.After.PBIAS)
+			}
+		}
+	}
+	fmt.Printf("预报流量过程已输出到: %s\n", *out)
+	return nil
+}
+
+// runAssimilate 以扰动降雨、蒸发驱动的集合逐时段同化observed_Q.txt中的实测流量，
+// 输出同化前后的集合均值及标准差
+func runAssimilate(args []string) error {
+	fs := flag.NewFlagSet("assimilate", flag.ExitOnError)
+	dir := fs.String("dir", ".", "数据目录，包含watershed.txt、P.txt、EM.txt、observed_Q.txt")
+	paramFile := fs.String("param", "", "参数文件，默认为数据目录下的parameter.txt")
+	method := fs.String("method", Assimilation.EnKF, "同化方法：enkf或pf")
+	members := fs.Int("members", 50, "集合成员（粒子）个数")
+	pSigma := fs.Float64("psigma", 0.3, "降雨乘性扰动的对数标准差")
+	emSigma := fs.Float64("emsigma", 0.1, "蒸发乘性扰动的相对标准差")
+	obsError := fs.Float64("obserr", 0.1, "实测流量的相对误差标准差")
+	minError := fs.Float64("minerr", 0.1, "实测流量误差标准差的下限，m3/s")
+	seed := fs.Int64("seed", 1, "随机数种子")
+	hotstart := fs.String("hotstart", "", "热启动快照文件，默认从initstate.txt或默认初始状态开始")
+	fill := fs.String("fill", Watershed.FillZero, "降雨、蒸发缺测插补方法：zero、linear或nearest")
+	out := fs.String("out", "", "同化结果输出文件，默认为数据目录下的assimilation.txt")
+	fs.Parse(args)
+
+	workPath := dirPath(*dir)
+	if *paramFile == "" {
+		*paramFile = workPath + "parameter.txt"
+	}
+	if *out == "" {
+		*out = workPath + "assimilation.txt"
+	}
+
+	var watershed Watershed.Watershed
+	if err := watershed.ReadFromFile(workPath); err != nil {
+		return err
+	}
+	var io Watershed.IO
+	io.FillMethod = *fill
+	if err := io.ReadFromFile(workPath); err != nil {
+		return err
+	}
+	if io.Q == nil {
+		return fmt.Errorf("数据同化需要实测流量observed_Q.txt")
+	}
+	if err := watershed.Calculate(&io); err != nil {
+		return err
+	}
+
+	parameter, err := readParameter(*paramFile)
+	if err != nil {
+		return err
+	}
+	units, err := readUnitParameters(workPath, watershed.GetnW(), parameter)
+	if err != nil {
+		return err
+	}
+	network, err := readNetwork(workPath)
+	if err != nil {
+		return err
+	}
+	var initial []*Data.State
+	var snapshot *Model.Snapshot
+	if *hotstart != "" {
+		if snapshot, err = readSnapshot(*hotstart, &io); err != nil {
+			return err
+		}
+	} else if _, err := os.Stat(workPath + Data.InitialStateFile); err == nil {
+		if initial, err = Data.ReadInitialStates(workPath, watershed.GetnW()); err != nil {
+			return err
+		}
+	}
+	dt := 24.0
+	if io.Step > 0 {
+		dt = io.Step.Hours()
+	}
+
+	// 各成员及不扰动的开环模拟使用相同的设置
+	build := func(ws *Watershed.Watershed) (*Model.Model, error) {
+		model, err := Model.NewModel(ws, parameter)
+		if err != nil {
+			return nil, err
+		}
+		if err := model.SetDt(dt); err != nil {
+			return nil, err
+		}
+		if err := model.SetNetwork(network); err != nil {
+			return nil, err
+		}
+		if err := model.SetUnitParameters(units); err != nil {
+			return nil, err
+		}
+		if snapshot != nil {
+			return model, model.Restore(snapshot)
+		}
+		if initial != nil {
+			return model, model.SetInitialStates(initial)
+		}
+		return model, nil
+	}
+
+	openLoop, err := build(&watershed)
+	if err != nil {
+		return err
+	}
+	openLoopResult, err := openLoop.Run(io.Nrows)
+	if err != nil {
+		return err
+	}
+	simulated := openLoopResult.Q
+
+	options := Assimilation.Options{
+		Method:   *method,
+		Members:  *members,
+		PSigma:   *pSigma,
+		EMSigma:  *emSigma,
+		ObsError: *obsError,
+		MinError: *minError,
+		Seed:     *seed,
+	}
+	filter, err := Assimilation.NewFilter(&watershed, options, build)
+	if err != nil {
+		return err
+	}
+	result, err := filter.Run(io.Q, io.Nrows)
+	if err != nil {
+		return err
+	}
+
+	names := []string{"open_loop", "prior_mean", "prior_spread", "mean", "spread", "observed"}
+	series := [][]float64{simulated, result.Prior, result.PriorSpread, result.Mean, result.Spread, io.Q}
+	if err := io.WriteSeries(*out, names, series); err != nil {
+		return err
+	}
+
+	fmt.Printf("同化方法: %s，集合成员%d个，同化%d个时段", *method, *members, result.Updated)
+	if *method == Assimilation.ParticleFilter {
+		fmt.Printf("，重采样%d次", result.Resampled)
+	}
+	fmt.Println()
+	fmt.Println("过程\tNSE\tRMSE\tPBIAS(%)")
+	for _, i := range []int{0, 1, 3} { // 开环模拟、同化前及同化后的集合均值
+		skill := Correction.Evaluate(series[i], io.Q)
+		fmt.Printf("%s\t%.4f\t%.3f\t%.2f\n", names[i], skill.NSE, skill.RMSE, skill.PBIAS)
+	}
+	fmt.Printf("同化结果已输出到: %s\n", *out)
+	return nil
+}
+
+// runConvert 由数据目录下的传统文本文件生成项目配置文件
+func runConvert(args []string) error {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	dir := fs.String("dir", ".", "数据目录，包含watershed.txt、P.txt、EM.txt及可选的time.txt、parameter.txt、scein.txt等")
+	out := fs.String("out", "", "项目配置输出文件，默认为数据目录下的"+Project.ConfigFile)
+	fs.Parse(args)
+
+	workPath := dirPath(*dir)
+	if *out == "" {
+		*out = workPath + Project.ConfigFile
+	}
+	cfg, err := Project.FromLegacy(*dir)
+	if err != nil {
+		return err
+	}
+	if len(cfg.Parameters) == 0 {
+		fmt.Println("提示: 数据目录下没有parameter.txt，须在配置的parameters中给出全部模型参数")
+	}
+	if _, err := os.Stat(workPath + "observe.txt"); err == nil {
+		fmt.Println("提示: observe.txt不写入配置，率定时使用data.observed给出的实测流量")
+	}
+	if err := cfg.WriteFile(*out); err != nil {
+		return err
+	}
+	fmt.Printf("项目配置已输出到: %s\n", *out)
+	return nil
+}
+
+// leadObserved 读取预见期的实测流量，文件不存在或没有时间信息时返回nil
+func leadObserved(fileName string, issue time.Time, step time.Duration, lead int) ([]float64, error) {
+	if _, err := os.Stat(fileName); err != nil {
+		return nil, nil
+	}
+	q, err := Watershed.ReadSeries(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if !q.HasTime() {
+		return nil, nil
+	}
+	ref := &Watershed.Series{Start: issue, Step: step, Values: make([][]float64, lead)}
+	if q, err = q.AlignTo(ref); err != nil {
+		return nil, &Watershed.ParseError{File: fileName, Err: err}
+	}
+	return q.Column(0), nil
+}
+
+// restoreSnapshot 读取快照作为模型初始状态
+func restoreSnapshot(model *Model.Model, fileName string, io *Watershed.IO) error {
+	snapshot, err := readSnapshot(fileName, io)
+	if err != nil {
+		return err
+	}
+	if err := model.Restore(snapshot); err != nil {
+		return err
+	}
+	fmt.Printf("从快照%s热启动\n", fileName)
+	return nil
+}
+
+// readSnapshot 读取快照，快照与驱动数据均有时间信息时检查两者是否衔接
+func readSnapshot(fileName string, io *Watershed.IO) (*Model.Snapshot, error) {
+	snapshot, err := Model.ReadSnapshot(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot.Time != "" && io.HasTime() {
+		t, err := Watershed.ParseTime(snapshot.Time)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fileName, err)
+		}
+		if next := t.Add(io.Step); !next.Equal(io.Start) {
+			return nil, fmt.Errorf("快照时刻%s之后应从%s开始计算，驱动数据从%s开始",
+				snapshot.Time, Watershed.FormatTime(next), Watershed.FormatTime(io.Start))
+		}
+	}
+	return snapshot, nil
+}
+
+// readParameter 读取模型参数，参数不合理时返回全部不合理之处，超出常用范围时仅提示
+func readParameter(fileName string) (*Data.Parameter, error) {
+	parameter := &Data.Parameter{}
+	if err := parameter.ReadFile(fileName); err != nil {
+		return nil, err
+	}
+	return checkParameter(parameter, fileName)
+}
+
+// checkParameter 检查来自source的模型参数，参数不合理时返回全部不合理之处，超出常用范围时仅提示
+func checkParameter(parameter *Data.Parameter, source string) (*Data.Parameter, error) {
+	if err := parameter.Validate(); err != nil {
+		return nil, fmt.Errorf("%s中的模型参数不合理:\n%w", source, err)
+	}
+	if err := parameter.CheckTypical(); err != nil {
+		fmt.Printf("提示: %s中的模型参数超出常用范围:\n%v\n", source, err)
+	}
+	return parameter, nil
+}
+
+// readUnitParameters 读取数据目录下的单元流域参数并以流域参数补全，文件不存在时返回nil
+func readUnitParameters(workPath string, nw int, parameter *Data.Parameter) ([]*Data.Parameter, error) {
+	if _, err := os.Stat(workPath + Data.UnitParameterFile); err != nil {
+		return nil, nil
+	}
+	units, err := Data.ReadUnitParameters(workPath, nw)
+	if err != nil {
+		return nil, err
+	}
+	return units.Resolve(parameter, nil), nil
+}
+
+// readNetwork 读取数据目录下的河网，文件不存在时返回nil
+func readNetwork(workPath string) (*Network.Network, error) {
+	if _, err := os.Stat(workPath + Network.NetworkFile); err != nil {
+		return nil, nil
+	}
+	network := &Network.Network{}
+	if err := network.ReadFromFile(workPath); err != nil {
+		return nil, err
+	}
+	fmt.Printf("经河网演算，共%d个河段、%d个控制断面\n", len(network.Reaches), len(network.Gauges))
+	return network, nil
+}
+
+// snapshotSteps 解析保存快照的时段，每项为已计算的时段数或该时段的时间戳
+func snapshotSteps(list string, io *Watershed.IO) ([]int, error) {
+	var steps []int
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		step, err := strconv.Atoi(item)
+		if err != nil {
+			if !io.HasTime() {
+				return nil, fmt.Errorf("驱动数据无时间信息，快照时段%s应为时段数", item)
+			}
+			t, err := Watershed.ParseTime(item)
+			if err != nil {
+				return nil, err
+			}
+			offset := t.Sub(io.Start)
+			if offset%io.Step != 0 {
+				return nil, fmt.Errorf("快照时间%s不在时段边界上，时段从%s开始、时段长%v", item, Watershed.FormatTime(io.Start), io.Step)
+			}
+			step = int(offset/io.Step) + 1
+		}
+		if step < 1 || step > io.Nrows {
+			return nil, fmt.Errorf("快照时段%s超出范围[1, %d]", item, io.Nrows)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// dirPath 返回以路径分隔符结尾的目录，模型各读写函数直接在其后拼接文件名
+func dirPath(dir string) string {
+	return filepath.Clean(dir) + string(filepath.Separator)
 }
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"demo2/Watershed"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSnapshotSteps(t *testing.T) {
+	io := &Watershed.IO{Nrows: 10, Start: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), Step: 6 * time.Hour}
+	tests := []struct {
+		list string
+		want []int // nil表示应返回错误
+	}{
+		{"1, 10", []int{1, 10}},
+		{"2000-01-01T06:00,2000-01-03T06:00", []int{2, 10}},
+		{"0", nil},
+		{"11", nil},
+		{"2000-01-01T07:00", nil},
+		{"1999-12-31T21:00", nil},
+		{"1999-12-31T18:00", nil},
+		{"2000-01-04", nil},
+		{"x", nil},
+	}
+	for _, tt := range tests {
+		got, err := snapshotSteps(tt.list, io)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%q应返回错误，实际为%v", tt.list, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q解析为%v、%v，应为%v", tt.list, got, err, tt.want)
+		}
+	}
+
+	if _, err := snapshotSteps("2000-01-01", &Watershed.IO{Nrows: 10}); err == nil {
+		t.Error("驱动数据无时间信息时快照时段应为时段数")
+	}
+}
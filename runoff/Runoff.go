@@ -39,6 +39,7 @@ func (r *Runoff) SetParmameter(parameter *Data.Parameter) {
 	r.IM = parameter.IM
 	r.WUM = parameter.UM
 	r.WLM = parameter.LM
+	r.WDM = parameter.WM - parameter.UM - parameter.LM
 }
 
 func (r *Runoff) SetState(state *Data.State) {